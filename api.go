@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiURL is the GitHub REST API, used by options needing details the
+// dependents pages don't show.
+const apiURL = "https://api.github.com"
+
+// graphQLBatchSize is the number of repositories looked up per GraphQL query
+// by fetchExactCounts.
+const graphQLBatchSize = 50
+
+// apiHeaders returns the headers for GitHub API requests, authenticated with
+// --token if set.
+func apiHeaders() http.Header {
+	headers := http.Header{}
+	headers.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		headers.Set("Authorization", "Bearer "+token)
+	}
+	return headers
+}
+
+// repoInfo holds the fields of the GitHub API repository object used by
+// topdep.
+type repoInfo struct {
+	Topics    []string  `json:"topics"`
+	Fork      bool      `json:"fork"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// repoInfoCache memoizes fetchRepoInfo by owner/name, so options sharing the
+// API data make one request per repo.
+var repoInfoCache = struct {
+	sync.Mutex
+	infos map[string]repoInfo
+}{infos: make(map[string]repoInfo)}
+
+// errRepoGone is returned by fetchRepoInfo for repositories that were
+// deleted or made private since GitHub listed them as dependents.
+var errRepoGone = errors.New("repository no longer exists")
+
+// fetchRepoInfo returns the API details of the repository at repoURL, from
+// the repo cache if it has fresh ones.
+func fetchRepoInfo(repoURL string) (repoInfo, error) {
+	fullName := strings.ToLower(repoFullName(repoURL))
+	repoInfoCache.Lock()
+	info, ok := repoInfoCache.infos[fullName]
+	repoInfoCache.Unlock()
+	if ok {
+		return info, nil
+	}
+	if info, ok := cachedRepoInfo(githubURL + "/" + fullName); ok {
+		repoInfoCache.Lock()
+		repoInfoCache.infos[fullName] = info
+		repoInfoCache.Unlock()
+		return info, nil
+	}
+
+	resp, err := request(http.MethodGet, apiURL+"/repos/"+fullName, nil, apiHeaders())
+	if err != nil {
+		return repoInfo{}, fmt.Errorf("failed to fetch %s from the API: %w", fullName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return repoInfo{}, errRepoGone
+	}
+	if resp.StatusCode != http.StatusOK {
+		return repoInfo{}, fmt.Errorf("failed to fetch %s from the API: unexpected status %s", fullName, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return repoInfo{}, fmt.Errorf("failed to parse API response for %s: %w", fullName, err)
+	}
+
+	repoInfoCache.Lock()
+	repoInfoCache.infos[fullName] = info
+	repoInfoCache.Unlock()
+	cacheRepoInfo(githubURL+"/"+fullName, info)
+	return info, nil
+}
+
+// fetchTopics sets the Topics of repo from the API. Repos that no longer
+// exist get no topics.
+func fetchTopics(repo Repo) (Repo, error) {
+	info, err := fetchRepoInfo(repo.URL)
+	if err != nil && !errors.Is(err, errRepoGone) {
+		return repo, err
+	}
+	repo.Topics = info.Topics
+	return repo, nil
+}
+
+// fetchExactCounts replaces the star and fork counts of repos, which GitHub
+// abbreviates on the dependents pages, with the exact ones from the GraphQL
+// API. Repos are looked up graphQLBatchSize at a time, one aliased field per
+// repo. Repos the API doesn't know anymore keep their scraped counts.
+func fetchExactCounts(repos []Repo) ([]Repo, error) {
+	result := append([]Repo(nil), repos...)
+	// lookup are the indexes of the repos whose counts aren't cached.
+	var lookup []int
+	for i, repo := range result {
+		if stars, forks, ok := cachedCounts(repo.URL); ok {
+			result[i].Stars, result[i].Forks, result[i].StarsMissing = stars, forks, false
+			continue
+		}
+		lookup = append(lookup, i)
+	}
+	for start := 0; start < len(lookup); start += graphQLBatchSize {
+		batch := lookup[start:min(start+graphQLBatchSize, len(lookup))]
+
+		var query strings.Builder
+		query.WriteString("query {")
+		for i, index := range batch {
+			owner, name, _ := strings.Cut(repoFullName(result[index].URL), "/")
+			fmt.Fprintf(&query, " r%d: repository(owner: %q, name: %q) { stargazerCount forkCount }", i, owner, name)
+		}
+		query.WriteString(" }")
+
+		body, err := json.Marshal(map[string]string{"query": query.String()})
+		if err != nil {
+			return nil, err
+		}
+		resp, err := request(http.MethodPost, apiURL+"/graphql", body, apiHeaders())
+		if err != nil {
+			return nil, fmt.Errorf("failed to query the GraphQL API: %w", err)
+		}
+		var answer struct {
+			Data map[string]*struct {
+				StargazerCount int `json:"stargazerCount"`
+				ForkCount      int `json:"forkCount"`
+			} `json:"data"`
+			Errors []struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&answer)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to query the GraphQL API: unexpected status %s", resp.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GraphQL response: %w", err)
+		}
+		for _, e := range answer.Errors {
+			// Deleted or private repos are reported as NOT_FOUND along
+			// with the data of the others.
+			if e.Type != "NOT_FOUND" {
+				return nil, fmt.Errorf("GraphQL API error: %s", e.Message)
+			}
+		}
+
+		for i, index := range batch {
+			repo := &result[index]
+			counts := answer.Data[fmt.Sprintf("r%d", i)]
+			if counts == nil {
+				logVerbose("%s not found by the API, keeping its scraped counts", repo.URL)
+				continue
+			}
+			repo.Stars = counts.StargazerCount
+			repo.StarsMissing = false
+			repo.Forks = counts.ForkCount
+			cacheCounts(repo.URL, repo.Stars, repo.Forks)
+		}
+	}
+	return result, nil
+}
+
+// fetchFork sets the Fork field of repo from the API. Repos that no longer
+// exist are left unset.
+func fetchFork(repo Repo) (Repo, error) {
+	info, err := fetchRepoInfo(repo.URL)
+	if errors.Is(err, errRepoGone) {
+		return repo, nil
+	}
+	if err != nil {
+		return repo, err
+	}
+	repo.Fork = &info.Fork
+	return repo, nil
+}
+
+// fetchCreatedAt sets the CreatedAt of repo from the API. Repos that no
+// longer exist are left without it.
+func fetchCreatedAt(repo Repo) (Repo, error) {
+	info, err := fetchRepoInfo(repo.URL)
+	if errors.Is(err, errRepoGone) {
+		return repo, nil
+	}
+	if err != nil {
+		return repo, err
+	}
+	repo.CreatedAt = &info.CreatedAt
+	return repo, nil
+}
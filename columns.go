@@ -0,0 +1,136 @@
+package main
+
+import "strings"
+
+// column is a table column. key is the name accepted by --column-names and
+// --columns-order, jsonKey the name of the matching JSON field. numeric
+// columns hold counts, float columns derived metrics rounded to --precision.
+type column struct {
+	key     string
+	jsonKey string
+	numeric bool
+	float   bool
+	value   func(Repo) interface{}
+}
+
+// tableColumns lists every table column in display order. Columns backed by
+// optional data are only shown when that data is requested, see
+// activeColumns.
+var tableColumns = []column{
+	{key: "name", jsonKey: "name", value: func(r Repo) interface{} { return displayName(r) }},
+	{key: "url", jsonKey: "url", value: func(r Repo) interface{} { return r.URL }},
+	{key: "stars", jsonKey: "stars", numeric: true, value: func(r Repo) interface{} { return r.Stars }},
+	{key: "forks", jsonKey: "forks", numeric: true, value: func(r Repo) interface{} { return r.Forks }},
+	{key: "normalized", jsonKey: "normalized_stars", float: true, value: func(r Repo) interface{} { return *r.NormalizedStars }},
+	{key: "percentile", jsonKey: "percentile", float: true, value: func(r Repo) interface{} { return *r.Percentile }},
+	{key: "dependents", jsonKey: "dependent_count", numeric: true, value: func(r Repo) interface{} { return dependentCount(r) }},
+	{key: "package", jsonKey: "package_name", value: func(r Repo) interface{} { return r.PackageName }},
+	{key: "ecosystem", jsonKey: "ecosystem", value: func(r Repo) interface{} { return r.Ecosystem }},
+}
+
+// activeColumns returns the table columns shown for the current flags. With
+// --fields, only the listed ones among them are shown.
+func activeColumns() []column {
+	var columns []column
+	for _, c := range tableColumns {
+		if c.key == "normalized" && !normalizeStars {
+			continue
+		}
+		if c.key == "percentile" && !percentileCol {
+			continue
+		}
+		// Streamed dependents never have their dependents looked up.
+		if c.key == "dependents" && (stream || depth < 2 && !sortsByDependents()) {
+			continue
+		}
+		if (c.key == "package" || c.key == "ecosystem") && dependentType != typePackage {
+			continue
+		}
+		if len(fields) > 0 && !containsKey(fields, c.key) {
+			continue
+		}
+		columns = append(columns, c)
+	}
+	return orderColumns(columns, columnsOrder)
+}
+
+// orderColumns moves the columns named in order to the front, in that order,
+// for --columns-order. The others keep their place after them.
+func orderColumns(columns []column, order []string) []column {
+	if len(order) == 0 {
+		return columns
+	}
+	var result []column
+	for _, key := range order {
+		for _, c := range columns {
+			if c.key == key {
+				result = append(result, c)
+			}
+		}
+	}
+	for _, c := range columns {
+		if !containsKey(order, c.key) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// jsonFieldOrder returns the JSON fields of the columns named by
+// --columns-order, in that order.
+func jsonFieldOrder() []string {
+	var fields []string
+	for _, key := range columnsOrder {
+		for _, c := range tableColumns {
+			if c.key == key {
+				fields = append(fields, c.jsonKey)
+			}
+		}
+	}
+	return fields
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func columnValues(columns []column, repo Repo) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, c := range columns {
+		values[i] = c.value(repo)
+	}
+	return values
+}
+
+func columnKeys() []string {
+	keys := make([]string, len(tableColumns))
+	for i, c := range tableColumns {
+		keys[i] = c.key
+	}
+	return keys
+}
+
+func isTableColumn(key string) bool {
+	for _, c := range tableColumns {
+		if c.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// columnHeader returns the header for a table column, honouring --column-names.
+func columnHeader(key string) string {
+	if header, ok := columnHeaders[key]; ok {
+		return header
+	}
+	if key == "url" {
+		return "URL"
+	}
+	return strings.ToUpper(key[:1]) + key[1:]
+}
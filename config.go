@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// configFile is the file given with --config.
+var configFile string
+
+// defaultConfigFile returns the config file read when --config isn't given,
+// e.g. ~/.config/topdep/config on Linux, or "" if there is no config
+// directory.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "topdep", "config")
+}
+
+// applyConfig sets the defaults of the flags of cmd from the config file,
+// one "flag = value" per line, e.g. "fields = name,url,stars". Flags given on
+// the command line take precedence. Blank lines and lines starting with # are
+// skipped. If optional is set, a missing file is not an error.
+func applyConfig(cmd *cobra.Command, name string, optional bool) error {
+	f, err := os.Open(name)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected flag = value", line)
+		}
+		key = strings.TrimPrefix(strings.TrimSpace(key), "--")
+		flag := cmd.Flags().Lookup(key)
+		if flag == nil || key == "config" {
+			return fmt.Errorf("line %d: unknown flag %q", line, key)
+		}
+		if flag.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(key, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return scanner.Err()
+}
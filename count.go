@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var countCmd = &cobra.Command{
+	Use:   "count [flags] URL",
+	Short: "Print the number of dependents GitHub reports, without crawling them",
+	Args:  cobra.ExactArgs(1),
+	Run:   runCount,
+}
+
+func init() {
+	countCmd.Flags().StringVar(&packageID, "package-id", "", "Only include dependents of this package, for repositories publishing several")
+	countCmd.Flags().StringVar(&dependentType, "type", typeRepository, "Type of dependents to count: "+strings.Join(dependentTypes, " or "))
+	countCmd.Flags().BoolVar(&isPackages, "packages", false, "Count package dependents (deprecated alias for --type package)")
+	countCmd.Flags().MarkDeprecated("packages", "use --type package instead")
+	countCmd.MarkFlagsMutuallyExclusive("type", "packages")
+	countCmd.Flags().Int64Var(&maxPageBytes, "max-page-bytes", defaultMaxPageBytes, "Parse at most this many bytes of each page, 0 for no limit")
+	rootCmd.AddCommand(countCmd)
+}
+
+func runCount(cmd *cobra.Command, args []string) {
+	resolveDependentType()
+	httpClient = newHTTPClient(maxIdleConns, disableHTTP2)
+
+	count, err := fetchUsedByCount(normalizeRepoURL(args[0]), dependentType, packageID)
+	if err != nil {
+		exitWithFetchError(err)
+	}
+	fmt.Println(count)
+}
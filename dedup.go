@@ -0,0 +1,98 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// dedupModes are the valid values of --dedup-mode.
+var dedupModes = []string{"exact", "bloom"}
+
+// bloomCapacity is the number of dependents a bloom filter is sized for when
+// --max-repos doesn't bound the crawl.
+const bloomCapacity = 1000000
+
+// dedupSet remembers the dependents seen during a crawl, so that dependents
+// GitHub lists on more than one page are only counted once.
+type dedupSet interface {
+	// add adds key and reports whether it was new.
+	add(key string) bool
+}
+
+// newDedupSet returns the set for --dedup-mode.
+func newDedupSet() dedupSet {
+	if dedupMode == "bloom" {
+		capacity := maxRepos
+		if capacity <= 0 {
+			capacity = bloomCapacity
+		}
+		return newBloomFilter(capacity, bloomFPRate)
+	}
+	return exactSet{}
+}
+
+func isDedupMode(mode string) bool {
+	for _, m := range dedupModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupKey identifies a dependent. Package dependents are told apart by
+// package as well, since a repository can publish several depending on the
+// same package.
+func dedupKey(repo Repo) string {
+	return strings.ToLower(repo.URL) + " " + repo.PackageName
+}
+
+type exactSet map[string]struct{}
+
+func (s exactSet) add(key string) bool {
+	if _, ok := s[key]; ok {
+		return false
+	}
+	s[key] = struct{}{}
+	return true
+}
+
+// bloomFilter is a dedupSet of bounded size. It may mistake a new key for a
+// seen one, at a rate close to the one it was created for as long as it holds
+// no more keys than its capacity, but never the other way around.
+type bloomFilter struct {
+	bits   []uint64
+	hashes uint64
+}
+
+// newBloomFilter returns a bloom filter for capacity keys with a false
+// positive rate of fpRate.
+func newBloomFilter(capacity int, fpRate float64) *bloomFilter {
+	m := math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(capacity)*math.Ln2))
+	return &bloomFilter{
+		bits:   make([]uint64, (uint64(m)+63)/64),
+		hashes: uint64(k),
+	}
+}
+
+func (f *bloomFilter) add(key string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	// The bit positions are derived from two halves of one hash
+	// (Kirsch-Mitzenmacher), which is as good as k independent hashes.
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	size := uint64(len(f.bits)) * 64
+	added := false
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % size
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if f.bits[word]&mask == 0 {
+			f.bits[word] |= mask
+			added = true
+		}
+	}
+	return added
+}
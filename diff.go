@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/spf13/cobra"
+)
+
+var diffFormat string
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [flags] OLD NEW",
+	Short: "Compare two dumps: star and fork changes, added and removed dependents",
+	Args:  cobra.ExactArgs(2),
+	Run:   runDiff,
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffFormat, "format", "table", "Output format: table or json")
+	rootCmd.AddCommand(diffCmd)
+}
+
+// repoDiff is how a dependent changed between two dumps. Status is "changed"
+// or "unchanged" for dependents in both, "added" for the ones only in the new
+// dump and "removed" for the ones only in the old dump, which keep their old
+// counts.
+type repoDiff struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	Status     string `json:"status"`
+	Stars      int    `json:"stars"`
+	StarsDelta int    `json:"stars_delta"`
+	Forks      int    `json:"forks"`
+	ForksDelta int    `json:"forks_delta"`
+}
+
+func runDiff(cmd *cobra.Command, args []string) {
+	if diffFormat != "table" && diffFormat != "json" {
+		exitWithError(1, "Unknown diff format %q (valid: table, json)", diffFormat)
+	}
+	oldRepos, err := loadRepos(args[0])
+	if err != nil {
+		exitWithError(1, "Error loading %s: %v", args[0], err)
+	}
+	newRepos, err := loadRepos(args[1])
+	if err != nil {
+		exitWithError(1, "Error loading %s: %v", args[1], err)
+	}
+
+	diffs := diffRepos(oldRepos, newRepos)
+	if diffFormat == "json" {
+		err = displayDiffJSON(os.Stdout, diffs)
+	} else {
+		displayDiffTable(os.Stdout, diffs)
+	}
+	if err != nil {
+		exitWithError(1, "Error writing output: %v", err)
+	}
+}
+
+// diffRepos joins oldRepos and newRepos by URL. Dependents in both come
+// first, by how many stars they gained and then by stars, followed by the
+// added and the removed ones, by stars.
+func diffRepos(oldRepos, newRepos []Repo) []repoDiff {
+	old := make(map[string]Repo, len(oldRepos))
+	for _, repo := range oldRepos {
+		old[strings.ToLower(repo.URL)] = repo
+	}
+
+	var kept, added, removed []repoDiff
+	seen := make(map[string]bool, len(newRepos))
+	for _, repo := range newRepos {
+		key := strings.ToLower(repo.URL)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		d := repoDiff{Name: repo.Name, URL: repo.URL, Stars: repo.Stars, Forks: repo.Forks, Status: "added"}
+		if before, ok := old[key]; ok {
+			d.StarsDelta = repo.Stars - before.Stars
+			d.ForksDelta = repo.Forks - before.Forks
+			d.Status = "unchanged"
+			if d.StarsDelta != 0 || d.ForksDelta != 0 {
+				d.Status = "changed"
+			}
+			kept = append(kept, d)
+		} else {
+			added = append(added, d)
+		}
+	}
+	for _, repo := range oldRepos {
+		key := strings.ToLower(repo.URL)
+		if !seen[key] {
+			seen[key] = true
+			removed = append(removed, repoDiff{Name: repo.Name, URL: repo.URL, Stars: repo.Stars, Forks: repo.Forks, Status: "removed"})
+		}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		if kept[i].StarsDelta != kept[j].StarsDelta {
+			return kept[i].StarsDelta > kept[j].StarsDelta
+		}
+		return kept[i].Stars > kept[j].Stars
+	})
+	sort.SliceStable(added, func(i, j int) bool { return added[i].Stars > added[j].Stars })
+	sort.SliceStable(removed, func(i, j int) bool { return removed[i].Stars > removed[j].Stars })
+	return append(append(kept, added...), removed...)
+}
+
+func displayDiffJSON(w io.Writer, diffs []repoDiff) error {
+	jsonData, err := json.MarshalIndent(diffs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+func displayDiffTable(w io.Writer, diffs []repoDiff) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{"Name", "Status", "Stars", "Δ Stars", "Forks", "Δ Forks"})
+	for _, d := range diffs {
+		stars, forks := formatDelta(d.StarsDelta), formatDelta(d.ForksDelta)
+		if d.Status == "added" || d.Status == "removed" {
+			stars, forks = "", ""
+		}
+		t.AppendRow(table.Row{displayName(Repo{Name: d.Name, URL: d.URL}), d.Status, d.Stars, stars, d.Forks, forks})
+	}
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 4, Align: text.AlignRight},
+		{Number: 6, Align: text.AlignRight},
+	})
+	t.SetStyle(tableStyle(tableStyleName))
+	t.Render()
+}
+
+// formatDelta formats a change in a count with its sign, e.g. +12 or -3.
+func formatDelta(delta int) string {
+	if delta > 0 {
+		return "+" + strconv.Itoa(delta)
+	}
+	return strconv.Itoa(delta)
+}
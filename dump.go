@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dumpFormat     string
+	dumpOutputFile string
+)
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump [flags] URL",
+	Short: "Crawl all dependents and save them unfiltered for offline processing",
+	Args:  cobra.ExactArgs(1),
+	Run:   runDump,
+}
+
+func init() {
+	dumpCmd.Flags().StringVar(&packageID, "package-id", "", "Only include dependents of this package, for repositories publishing several")
+	dumpCmd.Flags().StringVar(&dependentType, "type", typeRepository, "Type of dependents to dump: "+strings.Join(dependentTypes, " or "))
+	dumpCmd.Flags().BoolVar(&isPackages, "packages", false, "Dump package dependents (deprecated alias for --type package)")
+	dumpCmd.Flags().MarkDeprecated("packages", "use --type package instead")
+	dumpCmd.MarkFlagsMutuallyExclusive("type", "packages")
+	dumpCmd.Flags().StringVar(&dumpFormat, "format", "json", "Dump format: json or ndjson")
+	dumpCmd.Flags().StringVarP(&dumpOutputFile, "output-file", "o", "", "Write the dump to a file instead of stdout")
+	dumpCmd.Flags().Int64Var(&maxPageBytes, "max-page-bytes", defaultMaxPageBytes, "Parse at most this many bytes of each page, 0 for no limit")
+	rootCmd.AddCommand(dumpCmd)
+}
+
+func runDump(cmd *cobra.Command, args []string) {
+	if dumpFormat != "json" && dumpFormat != "ndjson" {
+		exitWithError(1, "Unknown dump format %q (valid: json, ndjson)", dumpFormat)
+	}
+
+	out := os.Stdout
+	if dumpOutputFile != "" {
+		f, err := os.Create(dumpOutputFile)
+		if err != nil {
+			exitWithError(1, "Error creating output file: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	resolveDependentType()
+	httpClient = newHTTPClient(maxIdleConns, disableHTTP2)
+	unfilteredCrawl = true
+
+	repos, _, err := fetchDependents(normalizeRepoURL(args[0]), dependentType, nil, nil)
+	if err != nil {
+		exitWithFetchError(err)
+	}
+
+	if dumpFormat == "ndjson" {
+		err = displayLines(out, repos)
+	} else {
+		err = displayJSON(out, repos)
+	}
+	if err != nil {
+		exitWithError(1, "Error writing output: %v", err)
+	}
+}
+
+// loadRepos reads repos saved by the dump command, either as a JSON array or
+// as NDJSON. Unknown fields and entries without a repository URL are
+// rejected, so files that aren't dumps fail clearly.
+func loadRepos(path string) ([]Repo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []Repo
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := dec.Decode(&repos); err != nil {
+			return nil, fmt.Errorf("%s is not a topdep dump: %v", path, err)
+		}
+	} else {
+		for line := 1; ; line++ {
+			var repo Repo
+			err := dec.Decode(&repo)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a topdep dump: entry %d: %v", path, line, err)
+			}
+			repos = append(repos, repo)
+		}
+	}
+
+	for i, repo := range repos {
+		if repoFullName(repo.URL) == "" {
+			return nil, fmt.Errorf("%s is not a topdep dump: entry %d has no repository url", path, i+1)
+		}
+	}
+	return repos, nil
+}
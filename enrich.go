@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// enrichRepos runs enrich on every repo with at most concurrency requests in
+// flight, and returns the enriched repos in their original order. Once an
+// enrich call fails no new work is started and the first error is returned.
+//
+// Rate limits are handled by getPage, which pauses every worker until the
+// limit resets.
+func enrichRepos(repos []Repo, concurrency int, enrich func(Repo) (Repo, error)) ([]Repo, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	result := make([]Repo, len(repos))
+	jobs := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				repo, err := enrich(repos[i])
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				result[i] = repo
+			}
+		}()
+	}
+
+	for i := range repos {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+// resolveRedirect requests repo's page and, if GitHub redirects it because the
+// repository was renamed or transferred, updates URL and Name to the canonical
+// location.
+func resolveRedirect(repo Repo) (Repo, error) {
+	resp, err := getPage(repo.URL)
+	if err != nil {
+		return repo, fmt.Errorf("failed to fetch %s: %w", repo.URL, err)
+	}
+	resp.Body.Close()
+
+	canonical := githubURL + resp.Request.URL.Path
+	fullName := repoFullName(canonical)
+	if fullName == "" || canonical == repo.URL {
+		return repo, nil
+	}
+	logVerbose("%s was moved to %s", repo.URL, canonical)
+	_, repo.Name, _ = strings.Cut(fullName, "/")
+	repo.URL = githubURL + "/" + fullName
+	return repo, nil
+}
+
+// applyRedirects returns repos with each repo of before that resolveRedirect
+// moved replaced by its resolved version, at the same index of after.
+func applyRedirects(repos, before, after []Repo) []Repo {
+	moved := make(map[string]Repo)
+	for i, repo := range before {
+		if repo.URL != after[i].URL {
+			moved[dedupKey(repo)] = after[i]
+		}
+	}
+	if len(moved) == 0 {
+		return repos
+	}
+	result := make([]Repo, len(repos))
+	for i, repo := range repos {
+		if resolved, ok := moved[dedupKey(repo)]; ok {
+			repo = resolved
+		}
+		result[i] = repo
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEnrichReposKeepsOrderAndCapsConcurrency(t *testing.T) {
+	var repos []Repo
+	for i := 0; i < 40; i++ {
+		repos = append(repos, Repo{Name: fmt.Sprint(i), Stars: i})
+	}
+
+	const concurrency = 3
+	var inFlight, peak atomic.Int64
+	got, err := enrichRepos(repos, concurrency, func(repo Repo) (Repo, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		// Later repos finish first, so results arrive out of order.
+		time.Sleep(time.Duration(40-repo.Stars) * 100 * time.Microsecond)
+		repo.Forks = repo.Stars * 2
+		return repo, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, repo := range got {
+		if repo.Stars != i || repo.Forks != 2*i {
+			t.Fatalf("result %d = %+v, want the enriched repo %d", i, repo, i)
+		}
+	}
+	if p := peak.Load(); p > concurrency || p < 2 {
+		t.Errorf("peak of %d enrich calls at once, want 2 to %d", p, concurrency)
+	}
+}
+
+func TestEnrichReposReturnsFirstError(t *testing.T) {
+	repos := []Repo{{Name: "ok"}, {Name: "first"}, {Name: "second"}, {Name: "later"}}
+	errFirst, errSecond := errors.New("first failed"), errors.New("second failed")
+	var calls atomic.Int64
+	got, err := enrichRepos(repos, 1, func(repo Repo) (Repo, error) {
+		calls.Add(1)
+		switch repo.Name {
+		case "first":
+			return repo, errFirst
+		case "second":
+			return repo, errSecond
+		}
+		return repo, nil
+	})
+	if !errors.Is(err, errFirst) || got != nil {
+		t.Errorf("enrichRepos = %v, %v; want nil, %v", got, err, errFirst)
+	}
+	// With one worker, at most the job already handed out runs after the
+	// failure.
+	if n := calls.Load(); n > 3 {
+		t.Errorf("enrich called %d times, want no new work after the error", n)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Errors returned by fetchDependents, so callers can tell failure kinds apart
+// with errors.Is. Network failures are returned as *url.Error.
+var (
+	// ErrRateLimited means GitHub kept rate limiting the crawl for longer
+	// than --max-rate-limit-wait allows.
+	ErrRateLimited = errors.New("rate limited by GitHub")
+	// ErrNotFound means the repository, or its dependents page, doesn't exist.
+	ErrNotFound = errors.New("repository not found")
+	// ErrSelectorMismatch means the page didn't have the expected structure,
+	// most likely because GitHub changed its markup.
+	ErrSelectorMismatch = errors.New("dependents page didn't match the expected layout")
+)
+
+// Exit codes used for errors returned by fetchDependents and for failed
+// checks. Any other failure exits with 1.
+const (
+	exitFailUnder        = 2
+	exitNoMatches        = 3
+	exitNotFound         = 4
+	exitRateLimited      = 5
+	exitSelectorMismatch = 6
+)
+
+// exitWithFetchError reports err with a hint matching its kind and exits with
+// the corresponding exit code.
+func exitWithFetchError(err error) {
+	code, hint := fetchErrorExit(err)
+	reportError(fmt.Sprintf("Error fetching dependents: %v", err), hint)
+	os.Exit(code)
+}
+
+// fetchErrorExit returns the exit code and the hint for err.
+func fetchErrorExit(err error) (code int, hint string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return exitNotFound, "Check that the URL points to a public GitHub repository."
+	case errors.Is(err, ErrRateLimited):
+		return exitRateLimited, "Try again later or raise --max-rate-limit-wait."
+	case errors.Is(err, ErrSelectorMismatch):
+		return exitSelectorMismatch, "GitHub may have changed its page layout; please open an issue."
+	}
+	return 1, ""
+}
+
+// exitWithError reports a formatted error message and exits with code.
+func exitWithError(code int, format string, args ...interface{}) {
+	reportError(fmt.Sprintf(format, args...), "")
+	os.Exit(code)
+}
+
+// reportError prints an error message and an optional hint. With a
+// machine-readable output format the error is written as a JSON object to
+// stderr, or to stdout with --errors-stdout, so pipelines can parse failures
+// the same way as results.
+func reportError(msg, hint string) {
+	if !isMachineFormat(format) {
+		fmt.Println(msg)
+		if hint != "" {
+			fmt.Println(hint)
+		}
+		return
+	}
+
+	w := os.Stderr
+	if errorsToStdout {
+		w = os.Stdout
+	}
+	jsonData, _ := json.Marshal(struct {
+		Error string `json:"error"`
+		Hint  string `json:"hint,omitempty"`
+	}{msg, hint})
+	fmt.Fprintln(w, string(jsonData))
+}
+
+// isMachineFormat reports whether an output format is meant to be parsed by
+// other programs.
+func isMachineFormat(name string) bool {
+	switch name {
+	case "json", "ndjson", "urls", "top", "csv", "badge":
+		return true
+	}
+	return false
+}
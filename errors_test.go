@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fetchFrom crawls the repository /o/r on a server answering with handler.
+func fetchFrom(t *testing.T, handler http.HandlerFunc) error {
+	t.Helper()
+	quietCrawl(t)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	_, _, err := fetchDependents(srv.URL+"/o/r", typeRepository, nil, func(PageProgress) {})
+	return err
+}
+
+func TestFetchDependentsErrors(t *testing.T) {
+	savedWait := maxRateLimitWait
+	maxRateLimitWait = time.Second
+	t.Cleanup(func() {
+		maxRateLimitWait = savedWait
+		rateLimit.Lock()
+		rateLimit.waited, rateLimit.resumeAt = 0, time.Time{}
+		rateLimit.Unlock()
+	})
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		want     error
+		wantCode int
+	}{
+		{
+			name:     "not found",
+			handler:  http.NotFound,
+			want:     ErrNotFound,
+			wantCode: exitNotFound,
+		},
+		{
+			name: "rate limited past --max-rate-limit-wait",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			want:     ErrRateLimited,
+			wantCode: exitRateLimited,
+		},
+		{
+			name: "no #dependents",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "<html><body><p>Something else</p></body></html>")
+			},
+			want:     ErrSelectorMismatch,
+			wantCode: exitSelectorMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fetchFrom(t, tt.handler)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want errors.Is %v", err, tt.want)
+			}
+			if code, hint := fetchErrorExit(err); code != tt.wantCode || hint == "" {
+				t.Errorf("fetchErrorExit = %d, %q; want %d with a hint", code, hint, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestFetchErrorExitForOtherErrors(t *testing.T) {
+	if code, hint := fetchErrorExit(errors.New("connection refused")); code != 1 || hint != "" {
+		t.Errorf("fetchErrorExit = %d, %q; want 1 without a hint", code, hint)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/textproto"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// httpClient is used for every request made during a run. It is configured
+// from the command line flags by newHTTPClient.
+var httpClient = http.DefaultClient
+
+// newHTTPClient returns a client that keeps up to maxIdleConns connections to
+// GitHub alive between pages. HTTP/2 is negotiated unless disableHTTP2 is set,
+// which forces HTTP/1.1 for networks whose proxies mishandle HTTP/2.
+func newHTTPClient(maxIdleConns int, disableHTTP2 bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConns
+	transport.ForceAttemptHTTP2 = !disableHTTP2
+	if disableHTTP2 {
+		// A non-nil, empty TLSNextProto map disables HTTP/2 negotiation.
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// requestHeaders are set on every request for a github.com page, from
+// --header and --cookie. They aren't sent to the API, so a session cookie
+// doesn't leave github.com.
+var requestHeaders = http.Header{}
+
+// parseHeaders parses "Key: Value" header flags.
+func parseHeaders(values []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("malformed header %q, expected \"Key: Value\"", value)
+		}
+		if strings.ContainsAny(val, "\r\n") {
+			return nil, fmt.Errorf("header %q must not contain line breaks", key)
+		}
+		headers.Add(textproto.CanonicalMIMEHeaderKey(key), strings.TrimSpace(val))
+	}
+	return headers, nil
+}
+
+// maxComputingRetries is how often a 202 response is retried before it is
+// returned to the caller.
+const maxComputingRetries = 5
+
+// computingDelay is the pause before retrying a 202 response.
+var computingDelay = 3 * time.Second
+
+// requestCount is the number of HTTP requests made during this run, including
+// retries.
+var requestCount atomic.Int64
+
+// retriesUsed is the number of retries made during this run across all pages,
+// checked against --retry-budget.
+var retriesUsed atomic.Int64
+
+// rateLimit is shared by every request in the run so that concurrent workers
+// all pause together once GitHub reports the budget is exhausted. waited is
+// the total pause time, checked against --max-rate-limit-wait.
+var rateLimit struct {
+	sync.Mutex
+	waited   time.Duration
+	resumeAt time.Time
+}
+
+// getPage fetches the GitHub page at url, with the headers from --header.
+func getPage(url string) (*http.Response, error) {
+	logVerbose("Fetching %s", redactURL(url))
+	return request(http.MethodGet, url, nil, requestHeaders)
+}
+
+// sensitiveParams are query parameters hidden by redactURL.
+var sensitiveParams = []string{"token", "access_token", "auth", "key", "password", "secret", "signature"}
+
+// redactURL returns rawURL for logging, with credentials and sensitive query
+// parameters replaced, so verbose logs can be shared. Everything else,
+// including pagination cursors, is kept to let the URL be opened in a browser.
+func redactURL(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "<unparsable URL>"
+	}
+	if u.User != nil {
+		u.User = neturl.User("REDACTED")
+	}
+	query := u.Query()
+	redacted := false
+	for name := range query {
+		for _, sensitive := range sensitiveParams {
+			if strings.EqualFold(name, sensitive) {
+				query.Set(name, "REDACTED")
+				redacted = true
+			}
+		}
+	}
+	if redacted {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// request sends a request with body, sent again on every attempt, and
+// headers, pausing until the rate limit resets whenever GitHub reports that
+// the request budget is exhausted. Network errors and server errors are
+// retried up to --retries-per-page times; rate limit pauses don't count as
+// retries. With --retry-budget, the retries of all pages together are limited
+// as well. 202 responses are retried until GitHub has computed the page.
+func request(method, url string, body []byte, headers http.Header) (*http.Response, error) {
+	retries := 0
+	computing := 0
+	for {
+		rateLimit.Lock()
+		resumeAt := rateLimit.resumeAt
+		rateLimit.Unlock()
+		time.Sleep(time.Until(resumeAt))
+
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range headers {
+			req.Header[key] = values
+		}
+
+		requestCount.Add(1)
+		resp, err := doLimited(req)
+		if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+			resp.Body.Close()
+			err = fmt.Errorf("server error: %s", resp.Status)
+		}
+		if err != nil {
+			if retries >= retriesPerPage {
+				return nil, err
+			}
+			if retryBudget > 0 && retriesUsed.Add(1) > int64(retryBudget) {
+				return nil, fmt.Errorf("retry budget of %d exhausted: %w", retryBudget, err)
+			}
+			retries++
+			backoff := time.Duration(1<<(retries-1)) * time.Second
+			logVerbose("Retrying %s in %s (%d/%d): %v", redactURL(url), backoff, retries, retriesPerPage, err)
+			time.Sleep(backoff)
+			continue
+		}
+
+		if resp.StatusCode == http.StatusAccepted && computing < maxComputingRetries {
+			// GitHub answers 202 while it is still computing the dependents
+			// of a repository that hasn't been queried recently.
+			resp.Body.Close()
+			computing++
+			logVerbose("GitHub is still computing %s, retrying in %s (%d/%d)", redactURL(url), computingDelay, computing, maxComputingRetries)
+			time.Sleep(computingDelay)
+			continue
+		}
+
+		wait, limited := rateLimitWait(resp)
+		if !limited {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if err := pauseForRateLimit(wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// hostSlots holds a semaphore per host, limiting the requests in flight to
+// each host to --max-host-conns however many workers --concurrency starts.
+var hostSlots = struct {
+	sync.Mutex
+	sems map[string]chan struct{}
+}{sems: make(map[string]chan struct{})}
+
+// doLimited sends req once a slot for its host is free. The slot is released
+// when the response body is closed, or right away if the request fails.
+func doLimited(req *http.Request) (*http.Response, error) {
+	if maxHostConns <= 0 {
+		return httpClient.Do(req)
+	}
+	hostSlots.Lock()
+	sem, ok := hostSlots.sems[req.URL.Host]
+	if !ok {
+		sem = make(chan struct{}, maxHostConns)
+		hostSlots.sems[req.URL.Host] = sem
+	}
+	hostSlots.Unlock()
+
+	sem <- struct{}{}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		<-sem
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { <-sem }}
+	return resp, nil
+}
+
+// releasingBody calls release once when the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// defaultMaxPageBytes is the default of --max-page-bytes. Dependents pages
+// are well below 1 MiB.
+const defaultMaxPageBytes = 16 << 20
+
+// maxPageBytes is the most bytes of a page that are parsed, or 0 for no
+// limit, so huge or malicious pages can't exhaust memory.
+var maxPageBytes int64 = defaultMaxPageBytes
+
+// limitPage returns body cut off after maxPageBytes, warning if the page at
+// pageURL is longer.
+func limitPage(body io.Reader, pageURL string) io.Reader {
+	if maxPageBytes <= 0 {
+		return body
+	}
+	return &pageLimiter{r: body, remaining: maxPageBytes, url: pageURL}
+}
+
+// pageLimiter is an io.LimitReader that warns when it cuts the page off.
+type pageLimiter struct {
+	r         io.Reader
+	remaining int64
+	url       string
+}
+
+func (l *pageLimiter) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		var b [1]byte
+		if n, _ := l.r.Read(b[:]); n > 0 && l.remaining == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s is larger than --max-page-bytes %d, parsing only the start of it\n", l.url, maxPageBytes)
+			// Warn only once per page.
+			l.remaining = -1
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// pauseForRateLimit schedules a pause of wait for all requests, unless a
+// pause covering it is already scheduled by another worker.
+func pauseForRateLimit(wait time.Duration) error {
+	rateLimit.Lock()
+	defer rateLimit.Unlock()
+
+	resumeAt := time.Now().Add(wait)
+	if !resumeAt.After(rateLimit.resumeAt) {
+		return nil
+	}
+	if rateLimit.waited+wait > maxRateLimitWait {
+		return fmt.Errorf("%w: reset in %s exceeds --max-rate-limit-wait (%s)",
+			ErrRateLimited, wait.Round(time.Second), maxRateLimitWait)
+	}
+	fmt.Fprintf(os.Stderr, "\nRate limited by GitHub, pausing for %s...\n", wait.Round(time.Second))
+	rateLimit.waited += wait
+	rateLimit.resumeAt = resumeAt
+	return nil
+}
+
+// pageDelay sleeps for a random time between --min-delay and --max-delay, to
+// make the crawl look less like a bot.
+func pageDelay() {
+	if maxDelay == 0 {
+		return
+	}
+	delay := minDelay
+	if maxDelay > minDelay {
+		delay += time.Duration(rand.Int63n(int64(maxDelay - minDelay + 1)))
+	}
+	time.Sleep(delay)
+}
+
+// rateLimitWait reports whether resp is a rate limit response and, if so, how
+// long to wait before retrying. It prefers X-RateLimit-Reset and falls back to
+// Retry-After, then to a minute when neither header is present.
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	exhausted := resp.Header.Get("X-RateLimit-Remaining") == "0"
+	if resp.StatusCode != http.StatusTooManyRequests &&
+		!(resp.StatusCode == http.StatusForbidden && exhausted) {
+		return 0, false
+	}
+
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil && exhausted {
+		wait := time.Until(time.Unix(reset, 0))
+		if wait < time.Second {
+			wait = time.Second
+		}
+		return wait, true
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	return time.Minute, true
+}
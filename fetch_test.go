@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	client := newHTTPClient(7, false)
+	transport := client.Transport.(*http.Transport)
+	if transport.MaxIdleConns != 7 || transport.MaxIdleConnsPerHost != 7 {
+		t.Errorf("MaxIdleConns = %d, MaxIdleConnsPerHost = %d; want 7", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	if !transport.ForceAttemptHTTP2 || transport.TLSNextProto != nil {
+		t.Errorf("HTTP/2 isn't negotiated by default: ForceAttemptHTTP2 = %v, TLSNextProto = %v", transport.ForceAttemptHTTP2, transport.TLSNextProto)
+	}
+
+	transport = newHTTPClient(7, true).Transport.(*http.Transport)
+	if transport.ForceAttemptHTTP2 {
+		t.Error("ForceAttemptHTTP2 is set with --disable-http2")
+	}
+	if transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Errorf("TLSNextProto = %v with --disable-http2, want an empty non-nil map", transport.TLSNextProto)
+	}
+	if transport == http.DefaultTransport {
+		t.Error("newHTTPClient changed the default transport")
+	}
+}
+
+// computingServer answers the first accepted requests with 202, like GitHub
+// while it computes the dependents, and the others with a dependents page.
+// It returns the page URL and the number of requests made so far.
+func computingServer(t *testing.T, accepted int) (string, func() int64) {
+	t.Helper()
+	savedDelay := computingDelay
+	computingDelay = time.Millisecond
+	t.Cleanup(func() { computingDelay = savedDelay })
+
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= int64(accepted) {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		fmt.Fprint(w, dependentsPage(1, []string{dependentRow("o", "dep", 10, 1)}, ""))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/o/r", requests.Load
+}
+
+func TestGetPageRetriesWhileComputing(t *testing.T) {
+	quietCrawl(t)
+	repoURL, requests := computingServer(t, 1)
+	repos, _, err := fetchDependents(repoURL, typeRepository, nil, func(PageProgress) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || requests() != 2 {
+		t.Errorf("got %d repos after %d requests, want 1 after 2", len(repos), requests())
+	}
+}
+
+func TestGetPageGivesUpWhileComputing(t *testing.T) {
+	quietCrawl(t)
+	repoURL, requests := computingServer(t, maxComputingRetries+10)
+	resp, err := getPage(repoURL + "/network/dependents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || requests() != maxComputingRetries+1 {
+		t.Errorf("got %s after %d requests, want 202 after %d", resp.Status, requests(), maxComputingRetries+1)
+	}
+
+	_, _, err = fetchDependents(repoURL, typeRepository, nil, func(PageProgress) {})
+	if err == nil || !strings.Contains(err.Error(), "202") {
+		t.Errorf("fetchDependents error = %v, want the unexpected 202 status", err)
+	}
+}
+
+// withHostConns sets --max-host-conns for the duration of the test.
+func withHostConns(t *testing.T, n int) {
+	t.Helper()
+	saved, savedClient := maxHostConns, httpClient
+	maxHostConns, httpClient = n, http.DefaultClient
+	t.Cleanup(func() { maxHostConns, httpClient = saved, savedClient })
+}
+
+func TestDoLimitedCapsRequestsPerHost(t *testing.T) {
+	withHostConns(t, 2)
+	var inFlight, peak atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := doLimited(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p != 2 {
+		t.Errorf("peak of %d requests in flight, want --max-host-conns 2", p)
+	}
+}
+
+func TestDoLimitedFreesSlotOnError(t *testing.T) {
+	withHostConns(t, 1)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			if _, err := doLimited(req); err == nil {
+				t.Error("request to a closed server succeeded")
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed requests didn't free their slot")
+	}
+}
+
+func TestLimitPageCutsAtMaxPageBytes(t *testing.T) {
+	saved := maxPageBytes
+	maxPageBytes = 10
+	t.Cleanup(func() { maxPageBytes = saved })
+
+	var data []byte
+	stderr := captureStderr(t, func() {
+		body := limitPage(strings.NewReader(strings.Repeat("x", 100)), "https://github.com/o/r")
+		var err error
+		if data, err = io.ReadAll(body); err != nil {
+			t.Error(err)
+		}
+		// Reading past the end again doesn't warn again.
+		if n, err := body.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+			t.Errorf("read after the cut = %d, %v; want 0, EOF", n, err)
+		}
+	})
+	if len(data) != 10 {
+		t.Errorf("read %d bytes, want --max-page-bytes 10", len(data))
+	}
+	if n := strings.Count(stderr, "larger than --max-page-bytes"); n != 1 {
+		t.Errorf("warned %d times, want once:\n%s", n, stderr)
+	}
+}
+
+func TestLimitPageKeepsSmallPages(t *testing.T) {
+	saved := maxPageBytes
+	maxPageBytes = 10
+	t.Cleanup(func() { maxPageBytes = saved })
+
+	for _, page := range []string{"short", strings.Repeat("x", 10)} {
+		var data []byte
+		stderr := captureStderr(t, func() {
+			data, _ = io.ReadAll(limitPage(strings.NewReader(page), "https://github.com/o/r"))
+		})
+		if string(data) != page || stderr != "" {
+			t.Errorf("page of %d bytes read as %q with warning %q", len(page), data, stderr)
+		}
+	}
+}
+
+func TestFetchUsedByCountRetriesWhileComputing(t *testing.T) {
+	quietCrawl(t)
+	repoURL, requests := computingServer(t, 2)
+	count, err := fetchUsedByCount(repoURL, typeRepository, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || requests() != 3 {
+		t.Errorf("got a count of %d after %d requests, want 1 after 3", count, requests())
+	}
+}
+
+func TestFetchUsedByCountErrors(t *testing.T) {
+	quietCrawl(t)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    error
+	}{
+		{"not found", http.NotFound, ErrNotFound},
+		{"no used by count", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `<html><body><div id="dependents"></div></body></html>`)
+		}, ErrSelectorMismatch},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(tt.handler)
+		_, err := fetchUsedByCount(srv.URL+"/o/r", typeRepository, "")
+		srv.Close()
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want errors.Is %v", tt.name, err, tt.want)
+		}
+	}
+}
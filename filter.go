@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// explainLimit is the number of filtered out repos listed by --explain.
+const explainLimit = 20
+
+// selfRepos are the queried repositories. Unless --include-self is set, they
+// are filtered out when listed as their own dependents, e.g. in monorepos.
+var selfRepos []string
+
+// sortKeys lists the values accepted by --sort.
+var sortKeys = []string{"stars", "forks", "name", "dependents", "random"}
+
+// filterRepos returns the repos with at least minStar stars, and at most
+// --max-stars if set, that aren't owned by any of owners, ignored by the
+// ignore file or left out by --filter-expr, keeping their order.
+func filterRepos(repos []Repo, minStar int, owners []string) []Repo {
+	var result []Repo
+	for _, repo := range repos {
+		if keepRepo(repo, minStar, owners) {
+			result = append(result, repo)
+		}
+	}
+	return result
+}
+
+// checkStarRange checks that --max-stars, if set, isn't below --minstar.
+// They may be equal, to keep only repos with exactly that many stars.
+func checkStarRange(minStar, maxStars int) error {
+	if maxStars > 0 && maxStars < minStar {
+		return fmt.Errorf("--max-stars (%d) must not be lower than --minstar (%d)", maxStars, minStar)
+	}
+	return nil
+}
+
+func keepRepo(repo Repo, minStar int, owners []string) bool {
+	return filterReason(repo, minStar, owners) == ""
+}
+
+// filterReason returns why repo is filtered out, or "" if it is kept.
+func filterReason(repo Repo, minStar int, owners []string) string {
+	if requireStars && repo.StarsMissing {
+		return "no star count on the dependents page (--require-stars)"
+	}
+	if repo.Stars < minStar {
+		return fmt.Sprintf("%d stars, below --minstar %d", repo.Stars, minStar)
+	}
+	if maxStars > 0 && repo.Stars > maxStars {
+		return fmt.Sprintf("%d stars, above --max-stars %d", repo.Stars, maxStars)
+	}
+	if ownedByAny(repo, owners) {
+		return fmt.Sprintf("owned by excluded owner %s", repoOwner(repo.URL))
+	}
+	if !includeSelf && isSelf(repo) {
+		return "the queried repository itself (use --include-self to keep it)"
+	}
+	if pattern := ignoredBy(repo); pattern != "" {
+		return fmt.Sprintf("matches ignore pattern %s", pattern)
+	}
+	if filterExpr != nil && !filterExpr.match(repo) {
+		return fmt.Sprintf("doesn't match --filter-expr %s", filterExprText)
+	}
+	return ""
+}
+
+// explainFiltered prints why the first limit filtered out repos were
+// excluded, for --explain. apiFiltered are the repos dropped by the filters
+// looked up with the API, with the details fetched for them.
+func explainFiltered(w io.Writer, repos, apiFiltered []Repo, minStar int, owners []string, limit int) {
+	enriched := make(map[string]Repo, len(apiFiltered))
+	for _, repo := range apiFiltered {
+		enriched[dedupKey(repo)] = repo
+	}
+	excluded := 0
+	for _, repo := range repos {
+		reason := filterReason(repo, minStar, owners)
+		if e, ok := enriched[dedupKey(repo)]; ok && reason == "" {
+			reason = apiFilterReason(e)
+		}
+		if reason == "" {
+			continue
+		}
+		if excluded < limit {
+			fmt.Fprintf(w, "  %s: %s\n", repo.URL, reason)
+		}
+		excluded++
+	}
+	if excluded > limit {
+		fmt.Fprintf(w, "  ... and %d more\n", excluded-limit)
+	}
+	if excluded == 0 {
+		fmt.Fprintln(w, "No dependents were filtered out")
+	}
+}
+
+// apiFilterReason returns why repo, enriched with the details looked up with
+// the API, is filtered out by --topic, --only-forks, --exclude-forks,
+// --created-before or --created-after, or "" if it is kept.
+func apiFilterReason(repo Repo) string {
+	if len(topics) > 0 && !hasAnyTopic(repo, topics) {
+		return fmt.Sprintf("has none of the topics %s (--topic)", strings.Join(topics, ", "))
+	}
+	if onlyForks || excludeForks {
+		switch {
+		case repo.Fork == nil:
+			return "no longer exists on GitHub"
+		case onlyForks && !*repo.Fork:
+			return "not a fork (--only-forks)"
+		case excludeForks && *repo.Fork:
+			return "a fork (--exclude-forks)"
+		}
+	}
+	if !createdBeforeTime.IsZero() || !createdAfterTime.IsZero() {
+		switch {
+		case repo.CreatedAt == nil:
+			return "no longer exists on GitHub"
+		case !createdBeforeTime.IsZero() && !repo.CreatedAt.Before(createdBeforeTime):
+			return fmt.Sprintf("created %s, not before --created-before %s", repo.CreatedAt.Format(time.DateOnly), createdBefore)
+		case repo.CreatedAt.Before(createdAfterTime):
+			return fmt.Sprintf("created %s, before --created-after %s", repo.CreatedAt.Format(time.DateOnly), createdAfter)
+		}
+	}
+	return ""
+}
+
+// droppedRepos returns the repos of before that aren't in after.
+func droppedRepos(before, after []Repo) []Repo {
+	kept := make(map[string]bool, len(after))
+	for _, repo := range after {
+		kept[dedupKey(repo)] = true
+	}
+	var dropped []Repo
+	for _, repo := range before {
+		if !kept[dedupKey(repo)] {
+			dropped = append(dropped, repo)
+		}
+	}
+	return dropped
+}
+
+// isSelf reports whether repo is one of the queried repositories.
+func isSelf(repo Repo) bool {
+	for _, self := range selfRepos {
+		if strings.EqualFold(repoFullName(repo.URL), repoFullName(self)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownedByAny reports whether repo is owned by any of owners. GitHub owner
+// names are case-insensitive, so they are compared that way.
+func ownedByAny(repo Repo, owners []string) bool {
+	owner := repoOwner(repo.URL)
+	for _, o := range owners {
+		if strings.EqualFold(owner, o) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByTopics returns the repos having at least one of topics, keeping
+// their order.
+func filterByTopics(repos []Repo, topics []string) []Repo {
+	var result []Repo
+	for _, repo := range repos {
+		if hasAnyTopic(repo, topics) {
+			result = append(result, repo)
+		}
+	}
+	return result
+}
+
+// hasAnyTopic reports whether repo has any of topics. GitHub topics are
+// lowercase, so they are compared case-insensitively.
+func hasAnyTopic(repo Repo, topics []string) bool {
+	for _, have := range repo.Topics {
+		for _, want := range topics {
+			if strings.EqualFold(have, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterForks returns the repos that are forks if forks is set, or the ones
+// that aren't otherwise, keeping their order. Repos whose fork status is
+// unknown are dropped by either.
+func filterForks(repos []Repo, forks bool) []Repo {
+	var result []Repo
+	for _, repo := range repos {
+		if repo.Fork != nil && *repo.Fork == forks {
+			result = append(result, repo)
+		}
+	}
+	return result
+}
+
+// createdBeforeTime and createdAfterTime are --created-before and
+// --created-after, parsed by parseDate.
+var createdBeforeTime, createdAfterTime time.Time
+
+// filterByCreated returns the repos created before before, unless it is
+// zero, and at or after after, unless it is zero, keeping their order. Repos
+// whose creation date is unknown are dropped.
+func filterByCreated(repos []Repo, before, after time.Time) []Repo {
+	var result []Repo
+	for _, repo := range repos {
+		if repo.CreatedAt == nil {
+			continue
+		}
+		if !before.IsZero() && !repo.CreatedAt.Before(before) {
+			continue
+		}
+		if !after.IsZero() && repo.CreatedAt.Before(after) {
+			continue
+		}
+		result = append(result, repo)
+	}
+	return result
+}
+
+// dateLayouts are the absolute dates accepted by parseDate, in UTC unless
+// they include a time zone.
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006"}
+
+// parseDate parses a date such as 2023-06-01, 2023-06 or 2023, a timestamp
+// such as 2023-06-01T12:00:00Z, or a time before now such as 30d, 2w, 6m or
+// 1y for days, weeks, months or years ago.
+func parseDate(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if len(s) > 1 {
+		if n, err := strconv.Atoi(s[:len(s)-1]); err == nil && n >= 0 {
+			switch unicode.ToLower(rune(s[len(s)-1])) {
+			case 'd':
+				return now.AddDate(0, 0, -n), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*n), nil
+			case 'm':
+				return now.AddDate(0, -n, 0), nil
+			case 'y':
+				return now.AddDate(-n, 0, 0), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date %q, expected a date such as 2023-06-01, 2023-06 or 2023, or an age such as 30d, 2w, 6m or 1y", s)
+}
+
+// compareRepos returns a negative number when a sorts before b by key, a
+// positive one when it sorts after b, and 0 when they are equal.
+func compareRepos(a, b Repo, key string) int {
+	switch key {
+	case "forks":
+		return b.Forks - a.Forks
+	case "dependents":
+		return dependentCount(b) - dependentCount(a)
+	case "name":
+		return strings.Compare(strings.ToLower(repoFullName(a.URL)), strings.ToLower(repoFullName(b.URL)))
+	default:
+		return b.Stars - a.Stars
+	}
+}
+
+// sortsByDependents reports whether --sort or --then-by needs the dependent
+// count of every matching repo.
+func sortsByDependents() bool {
+	return sortKey == "dependents" || thenBy == "dependents"
+}
+
+func isSortKey(key string) bool {
+	for _, k := range sortKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplainFilteredIncludesAPIFilters(t *testing.T) {
+	withFilters(t, 0, false)
+	savedTopics, savedOnlyForks := topics, onlyForks
+	topics, onlyForks = []string{"cli"}, true
+	t.Cleanup(func() { topics, onlyForks = savedTopics, savedOnlyForks })
+
+	notFork := false
+	repos := []Repo{
+		{URL: "https://github.com/o/untagged"},
+		{URL: "https://github.com/o/original"},
+		{URL: "https://github.com/o/gone"},
+		{URL: "https://github.com/o/kept"},
+	}
+	apiFiltered := []Repo{
+		{URL: "https://github.com/o/untagged", Topics: []string{"web"}},
+		{URL: "https://github.com/o/original", Topics: []string{"cli"}, Fork: &notFork},
+		{URL: "https://github.com/o/gone", Topics: []string{"CLI"}},
+	}
+
+	var out bytes.Buffer
+	explainFiltered(&out, repos, apiFiltered, 0, nil, explainLimit)
+	for _, want := range []string{
+		"https://github.com/o/untagged: has none of the topics cli (--topic)",
+		"https://github.com/o/original: not a fork (--only-forks)",
+		"https://github.com/o/gone: no longer exists on GitHub",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("explanation lacks %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "o/kept") {
+		t.Errorf("kept repo is explained as filtered out:\n%s", out.String())
+	}
+}
+
+func TestFilterReposStarBand(t *testing.T) {
+	saved := maxStars
+	t.Cleanup(func() { maxStars = saved })
+
+	repos := []Repo{testRepo("o", "a", 9, 0), testRepo("o", "b", 10, 0), testRepo("o", "c", 11, 0)}
+	tests := []struct {
+		min, max int
+		want     []string
+	}{
+		{0, 0, []string{"o/a", "o/b", "o/c"}},
+		{10, 0, []string{"o/b", "o/c"}},
+		{0, 10, []string{"o/a", "o/b"}},
+		// --minstar and --max-stars equal keep exactly that many stars.
+		{10, 10, []string{"o/b"}},
+		{12, 12, nil},
+	}
+	for _, tt := range tests {
+		if err := checkStarRange(tt.min, tt.max); err != nil {
+			t.Errorf("checkStarRange(%d, %d): %v", tt.min, tt.max, err)
+		}
+		maxStars = tt.max
+		if got := fullNames(filterRepos(repos, tt.min, nil)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("--minstar %d --max-stars %d kept %v, want %v", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestCheckStarRangeRejectsMaxBelowMin(t *testing.T) {
+	err := checkStarRange(10, 9)
+	if err == nil {
+		t.Fatal("checkStarRange(10, 9) succeeded, want an error")
+	}
+	if want := "--max-stars (9) must not be lower than --minstar (10)"; err.Error() != want {
+		t.Errorf("checkStarRange(10, 9) = %q, want %q", err, want)
+	}
+}
+
+func TestFilterReason(t *testing.T) {
+	savedMax, savedSelf, savedIncludeSelf, savedIgnore := maxStars, selfRepos, includeSelf, ignorePatterns
+	savedExpr, savedExprText, savedRequire := filterExpr, filterExprText, requireStars
+	t.Cleanup(func() {
+		maxStars, selfRepos, includeSelf, ignorePatterns = savedMax, savedSelf, savedIncludeSelf, savedIgnore
+		filterExpr, filterExprText, requireStars = savedExpr, savedExprText, savedRequire
+	})
+	expr, err := parseFilterExpr("forks>0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		repo   Repo
+		set    func()
+		min    int
+		owners []string
+		want   string
+	}{
+		{name: "kept", repo: testRepo("o", "dep", 10, 1), min: 10},
+		{name: "below --minstar", repo: testRepo("o", "dep", 9, 1), min: 10,
+			want: "9 stars, below --minstar 10"},
+		{name: "above --max-stars", repo: testRepo("o", "dep", 11, 1), set: func() { maxStars = 10 },
+			want: "11 stars, above --max-stars 10"},
+		{name: "excluded owner", repo: testRepo("Corp", "dep", 10, 1), owners: []string{"corp"},
+			want: "owned by excluded owner Corp"},
+		{name: "self", repo: testRepo("acme", "lib", 10, 1), set: func() { selfRepos = []string{githubURL + "/ACME/lib"} },
+			want: "the queried repository itself (use --include-self to keep it)"},
+		{name: "self with --include-self", repo: testRepo("acme", "lib", 10, 1),
+			set: func() { selfRepos, includeSelf = []string{githubURL + "/acme/lib"}, true }},
+		{name: "ignored owner", repo: testRepo("Bots", "dep", 10, 1), set: func() { ignorePatterns = []string{"bots"} },
+			want: "matches ignore pattern bots"},
+		{name: "ignored repo", repo: testRepo("o", "dep-fork", 10, 1), set: func() { ignorePatterns = []string{"o/*-fork"} },
+			want: "matches ignore pattern o/*-fork"},
+		{name: "not matching --filter-expr", repo: testRepo("o", "dep", 10, 0),
+			set:  func() { filterExpr, filterExprText = expr, "forks>0" },
+			want: "doesn't match --filter-expr forks>0"},
+		{name: "stars missing with --require-stars", repo: Repo{URL: githubURL + "/o/dep", StarsMissing: true},
+			set:  func() { requireStars = true },
+			want: "no star count on the dependents page (--require-stars)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxStars, selfRepos, includeSelf, ignorePatterns = 0, nil, false, nil
+			filterExpr, filterExprText, requireStars = nil, "", false
+			if tt.set != nil {
+				tt.set()
+			}
+			if got := filterReason(tt.repo, tt.min, tt.owners); got != tt.want {
+				t.Errorf("filterReason = %q, want %q", got, tt.want)
+			}
+			if kept := len(filterRepos([]Repo{tt.repo}, tt.min, tt.owners)) == 1; kept != (tt.want == "") {
+				t.Errorf("filterRepos kept the repo: %v", kept)
+			}
+		})
+	}
+}
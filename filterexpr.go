@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filterExpr is the parsed --filter-expr, or nil if none was given.
+// Dependents it doesn't match are filtered out.
+var filterExpr exprNode
+
+// exprFields are the repo fields a filter expression can compare. All but
+// stars and forks are strings, which compare case-insensitively and only
+// support == and !=.
+var exprFields = []string{"stars", "forks", "owner", "name", "repo", "url", "package", "ecosystem"}
+
+// exprNode is a node of a parsed filter expression.
+type exprNode interface {
+	match(repo Repo) bool
+}
+
+type andExpr struct{ left, right exprNode }
+
+func (e andExpr) match(repo Repo) bool { return e.left.match(repo) && e.right.match(repo) }
+
+type orExpr struct{ left, right exprNode }
+
+func (e orExpr) match(repo Repo) bool { return e.left.match(repo) || e.right.match(repo) }
+
+type notExpr struct{ expr exprNode }
+
+func (e notExpr) match(repo Repo) bool { return !e.expr.match(repo) }
+
+// compareExpr compares a field of the repo with a value, e.g. "stars>100".
+type compareExpr struct {
+	field string
+	op    string
+	num   int
+	str   string
+}
+
+func (e compareExpr) match(repo Repo) bool {
+	if !isNumericField(e.field) {
+		equal := strings.EqualFold(exprString(repo, e.field), e.str)
+		return equal == (e.op == "==")
+	}
+	n := exprNumber(repo, e.field)
+	switch e.op {
+	case "==":
+		return n == e.num
+	case "!=":
+		return n != e.num
+	case "<":
+		return n < e.num
+	case "<=":
+		return n <= e.num
+	case ">":
+		return n > e.num
+	default:
+		return n >= e.num
+	}
+}
+
+func isNumericField(field string) bool {
+	return field == "stars" || field == "forks"
+}
+
+func exprNumber(repo Repo, field string) int {
+	if field == "forks" {
+		return repo.Forks
+	}
+	return repo.Stars
+}
+
+func exprString(repo Repo, field string) string {
+	switch field {
+	case "owner":
+		return repoOwner(repo.URL)
+	case "name":
+		return repo.Name
+	case "repo":
+		return repoFullName(repo.URL)
+	case "package":
+		return repo.PackageName
+	case "ecosystem":
+		return repo.Ecosystem
+	default:
+		return repo.URL
+	}
+}
+
+// exprToken is a token of a filter expression and its position, counted in
+// characters from 1 for error messages.
+type exprToken struct {
+	text   string
+	quoted bool
+	pos    int
+}
+
+// parseFilterExpr parses a filter expression such as
+// "stars>100 && forks<50 && owner!=mycorp". Comparisons can be combined with
+// && and ||, negated with ! and grouped with parentheses; && binds tighter
+// than ||.
+func parseFilterExpr(s string) (exprNode, error) {
+	tokens, err := tokenizeFilterExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	p := exprParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+	return expr, nil
+}
+
+func tokenizeFilterExpr(s string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, exprToken{text: string(r), pos: i + 1})
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, exprToken{text: string(runes[i+1 : end]), quoted: true, pos: i + 1})
+			i = end + 1
+		case strings.ContainsRune("&|!=<>", r):
+			op := string(r)
+			if i+1 < len(runes) {
+				if two := string(runes[i : i+2]); two == "&&" || two == "||" || two == "==" || two == "!=" || two == "<=" || two == ">=" {
+					op = two
+				}
+			}
+			if op == "&" || op == "|" || op == "=" {
+				return nil, fmt.Errorf("unexpected %q at position %d (did you mean %q?)", op, i+1, op+op)
+			}
+			tokens = append(tokens, exprToken{text: op, pos: i + 1})
+			i += len(op)
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("()\"'&|!=<>", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, exprToken{text: string(runes[i:end]), pos: i + 1})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	next   int
+}
+
+func (p *exprParser) peek() (exprToken, bool) {
+	if p.next >= len(p.tokens) {
+		return exprToken{}, false
+	}
+	return p.tokens[p.next], true
+}
+
+// accept consumes the next token if it is the operator text.
+func (p *exprParser) accept(text string) bool {
+	if t, ok := p.peek(); ok && !t.quoted && t.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right exprNode
+		right, err = p.parseAnd()
+		left = orExpr{left, right}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.accept("&&") {
+		var right exprNode
+		right, err = p.parseUnary()
+		left = andExpr{left, right}
+	}
+	return left, err
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.accept("!") {
+		expr, err := p.parseUnary()
+		return notExpr{expr}, err
+	}
+	if t, ok := p.peek(); ok && p.accept("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ) for ( at position %d", t.pos)
+		}
+		return expr, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	field, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of expression, expected a field")
+	}
+	name := strings.ToLower(field.text)
+	if !isExprField(name) || field.quoted {
+		return nil, fmt.Errorf("unknown field %q at position %d (valid: %s)", field.text, field.pos, strings.Join(exprFields, ", "))
+	}
+	p.next++
+
+	op, ok := p.peek()
+	if !ok || op.quoted || !isCompareOp(op.text) {
+		return nil, fmt.Errorf("expected a comparison after %s at position %d", field.text, field.pos)
+	}
+	p.next++
+
+	value, ok := p.peek()
+	if !ok || !value.quoted && !isExprValue(value.text) {
+		return nil, fmt.Errorf("expected a value after %s%s at position %d", field.text, op.text, op.pos)
+	}
+	p.next++
+
+	expr := compareExpr{field: name, op: op.text, str: value.text}
+	if isNumericField(name) {
+		n, err := strconv.Atoi(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("%s needs a number, not %q at position %d", field.text, value.text, value.pos)
+		}
+		expr.num = n
+	} else if op.text != "==" && op.text != "!=" {
+		return nil, fmt.Errorf("%s can only be compared with == and != at position %d", field.text, op.pos)
+	}
+	return expr, nil
+}
+
+func isCompareOp(s string) bool {
+	switch s {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// isExprValue reports whether an unquoted token can be a value, rather than
+// an operator or parenthesis.
+func isExprValue(s string) bool {
+	return !isCompareOp(s) && s != "&&" && s != "||" && s != "!" && s != "(" && s != ")"
+}
+
+func isExprField(name string) bool {
+	for _, f := range exprFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
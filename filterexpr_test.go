@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestFilterExprMatch(t *testing.T) {
+	repo := Repo{Name: "acme/my lib", URL: "https://github.com/acme/my-lib", Stars: 150, Forks: 5, PackageName: "left-pad"}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"stars>100", true},
+		{"stars>=150 && stars<=150", true},
+		{"stars<150 || forks!=5", false},
+		// && binds tighter than ||.
+		{"stars>100 || forks>10 && owner==other", true},
+		{"(stars>100 || forks>10) && owner==other", false},
+		{"forks>10 && owner==other || stars>100", true},
+		{"!stars>100", false},
+		{"!(owner==other) && !!stars==150", true},
+		{"!(stars>100 && forks>1)", false},
+		// Strings compare case-insensitively and can be quoted.
+		{"owner==ACME", true},
+		{`name=="acme/my lib"`, true},
+		{`name=='ACME/MY LIB'`, true},
+		{"repo==acme/my-lib && package!=lodash", true},
+		{`package=="left-pad" && ecosystem==""`, true},
+		{"url==https://github.com/acme/my-lib", true},
+	}
+	for _, tt := range tests {
+		expr, err := parseFilterExpr(tt.expr)
+		if err != nil {
+			t.Errorf("parseFilterExpr(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := expr.match(repo); got != tt.want {
+			t.Errorf("%q matched %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestFilterExprSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "unexpected end of expression, expected a field"},
+		{"stars>1 &&", "unexpected end of expression, expected a field"},
+		{"stars>1 forks>1", `unexpected "forks" at position 9`},
+		{"stars>1 )", `unexpected ")" at position 9`},
+		{"stars>100 & forks>1", `unexpected "&" at position 11 (did you mean "&&"?)`},
+		{"stars>1 | forks>1", `unexpected "|" at position 9 (did you mean "||"?)`},
+		{"owner=acme", `unexpected "=" at position 6 (did you mean "=="?)`},
+		{`name=="my lib`, "unterminated string at position 7"},
+		{"(stars>1 || forks>1", "missing ) for ( at position 1"},
+		{"size>1", `unknown field "size" at position 1 (valid: stars, forks, owner, name, repo, url, package, ecosystem)`},
+		{`"stars">1`, `unknown field "stars" at position 1 (valid: stars, forks, owner, name, repo, url, package, ecosystem)`},
+		{"stars", "expected a comparison after stars at position 1"},
+		{"stars forks", "expected a comparison after stars at position 1"},
+		{"stars>", "expected a value after stars> at position 6"},
+		{"stars>=)", "expected a value after stars>= at position 6"},
+		{"stars>many", `stars needs a number, not "many" at position 7`},
+		{"owner>acme", "owner can only be compared with == and != at position 6"},
+	}
+	for _, tt := range tests {
+		_, err := parseFilterExpr(tt.expr)
+		if err == nil {
+			t.Errorf("parseFilterExpr(%q) succeeded, want %q", tt.expr, tt.want)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("parseFilterExpr(%q) = %q, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// dependentRow renders a row of a dependents page the way GitHub does.
+func dependentRow(owner, name string, stars, forks int) string {
+	return fmt.Sprintf(`<div class="Box-row d-flex flex-items-center" data-test-id="dg-repo-pkg-dependent">
+  <span class="f5 color-fg-muted">
+    <a data-hovercard-type="user" href="/%[1]s">%[1]s</a> /
+    <a class="text-bold" data-hovercard-type="repository" href="/%[1]s/%[2]s">%[2]s</a>
+  </span>
+  <div class="d-flex flex-auto flex-justify-end">
+    <span class="color-fg-muted text-bold pl-3"><svg class="octicon octicon-star"></svg> %[3]d</span>
+    <span class="color-fg-muted text-bold pl-3"><svg class="octicon octicon-repo-forked"></svg> %[4]d</span>
+  </div>
+</div>`, owner, name, stars, forks)
+}
+
+// dependentsPage renders a dependents page listing rows under a "Used by"
+// header of usedBy, with a Next link to next unless it is "".
+func dependentsPage(usedBy int, rows []string, next string) string {
+	var b strings.Builder
+	b.WriteString(`<html><body><div id="dependents">`)
+	fmt.Fprintf(&b, `<div class="table-list-header-toggle"><a class="btn-link selected" href="?dependent_type=REPOSITORY">%d Repositories</a></div>`, usedBy)
+	b.WriteString(`<div class="Box">` + strings.Join(rows, "") + `</div>`)
+	if next != "" {
+		fmt.Fprintf(&b, `<div class="paginate-container"><div class="BtnGroup"><a class="btn BtnGroup-item" href="%s">Next</a></div></div>`, next)
+	}
+	b.WriteString(`</div></body></html>`)
+	return b.String()
+}
+
+// parseHTML parses an HTML fixture.
+func parseHTML(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// newDependentsServer serves pages dependents pages of perPage rows each for
+// the repository /o/r, linked by ?page=N. It returns the server and the
+// repository URL on it.
+func newDependentsServer(t *testing.T, pages, perPage int) (*httptest.Server, string) {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/o/r/network/dependents" {
+			http.NotFound(w, r)
+			return
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+		var rows []string
+		for i := 0; i < perPage; i++ {
+			n := (page-1)*perPage + i
+			rows = append(rows, dependentRow("user"+strconv.Itoa(n), "repo"+strconv.Itoa(n), n*10, n))
+		}
+		next := ""
+		if page < pages {
+			next = fmt.Sprintf("%s/o/r/network/dependents?dependent_type=REPOSITORY&page=%d", srv.URL, page+1)
+		}
+		fmt.Fprint(w, dependentsPage(pages*perPage, rows, next))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, srv.URL + "/o/r"
+}
+
+// quietCrawl silences crawl summaries and uses a plain HTTP client for the
+// duration of the test.
+func quietCrawl(t *testing.T) {
+	t.Helper()
+	savedQuiet, savedClient := quiet, httpClient
+	quiet, httpClient = true, http.DefaultClient
+	t.Cleanup(func() { quiet, httpClient = savedQuiet, savedClient })
+}
+
+// captureStderr returns what f writes to stderr.
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = saved }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-output
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// histogramBarWidth is the length of the bar of the largest bucket in the
+// text histogram.
+const histogramBarWidth = 40
+
+// histogramBucket counts the dependents with at least Min stars and fewer
+// than the Min of the next bucket.
+type histogramBucket struct {
+	Label string `json:"label"`
+	Min   int    `json:"min"`
+	Count int    `json:"count"`
+}
+
+// starHistogram buckets repos by the order of magnitude of their stars: 0-9,
+// 10-99 and so on up to 100000 and more.
+func starHistogram(repos []Repo) []histogramBucket {
+	buckets := []histogramBucket{
+		{Label: "0-9", Min: 0},
+		{Label: "10-99", Min: 10},
+		{Label: "100-999", Min: 100},
+		{Label: "1000-9999", Min: 1000},
+		{Label: "10000-99999", Min: 10000},
+		{Label: "100000+", Min: 100000},
+	}
+	for _, repo := range repos {
+		i := len(buckets) - 1
+		for i > 0 && repo.Stars < buckets[i].Min {
+			i--
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// displayHistogram writes buckets as ASCII bars scaled to the largest bucket.
+func displayHistogram(w io.Writer, buckets []histogramBucket) {
+	largest, labelWidth := 0, 0
+	for _, b := range buckets {
+		largest = max(largest, b.Count)
+		labelWidth = max(labelWidth, len(b.Label))
+	}
+	fmt.Fprintln(w, "Stars of fetched dependents:")
+	for _, b := range buckets {
+		bar := 0
+		if largest > 0 {
+			bar = (b.Count*histogramBarWidth + largest - 1) / largest
+		}
+		fmt.Fprintf(w, "  %*s | %s\n", labelWidth, b.Label, strings.TrimSpace(strings.Repeat("#", bar)+" "+fmt.Sprint(b.Count)))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// avatarSize is the width and height in pixels of owner avatars shown with
+// --avatars.
+const avatarSize = 20
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Dependents</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 4px 8px; text-align: left; }
+td.numeric { text-align: right; }
+img.avatar { border-radius: 50%; vertical-align: middle; margin-right: 4px; }
+</style>
+</head>
+<body>
+<table>
+<thead>
+<tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>
+</thead>
+<tbody>
+{{- range .Rows}}
+<tr>
+{{- range .Cells}}
+{{- if .Link}}<td>{{if .Avatar}}<a href="{{.OwnerURL}}"><img class="avatar" src="{{.Avatar}}" width="{{$.AvatarSize}}" height="{{$.AvatarSize}}" alt="{{.Owner}}"></a>{{end}}<a href="{{.Link}}">{{.Value}}</a></td>
+{{- else if .Numeric}}<td class="numeric">{{.Value}}</td>
+{{- else}}<td>{{.Value}}</td>
+{{- end}}
+{{- end}}
+</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+type htmlCell struct {
+	Value   string
+	Numeric bool
+	// Link is set for the name and URL columns, which link to the repo.
+	// The name column also shows the owner's avatar with --avatars.
+	Link     string
+	Owner    string
+	OwnerURL string
+	Avatar   string
+}
+
+// displayHTML writes the repos as a standalone HTML page with a table of the
+// table columns. With --avatars, names are preceded by the owner's avatar
+// linking to their profile. Avatars are loaded from github.com/<owner>.png,
+// which needs no API request.
+func displayHTML(w io.Writer, repos []Repo) error {
+	columns := activeColumns()
+	data := struct {
+		Headers    []string
+		Rows       []struct{ Cells []htmlCell }
+		AvatarSize int
+	}{AvatarSize: avatarSize}
+
+	for _, c := range columns {
+		data.Headers = append(data.Headers, columnHeader(c.key))
+	}
+	for _, repo := range repos {
+		var cells []htmlCell
+		for i, value := range columnValues(columns, repo) {
+			cell := htmlCell{Numeric: columns[i].numeric || columns[i].float}
+			switch {
+			case columns[i].float:
+				cell.Value = precisionTransformer(value)
+			case humanNumbers && columns[i].numeric:
+				cell.Value = humanNumberTransformer(value)
+			default:
+				cell.Value = fmt.Sprint(value)
+			}
+			if columns[i].key == "name" || columns[i].key == "url" {
+				cell.Link = repo.URL
+			}
+			if columns[i].key == "name" && avatars {
+				if owner := repoOwner(repo.URL); owner != "" {
+					cell.Owner = owner
+					cell.OwnerURL = githubURL + "/" + owner
+					cell.Avatar = fmt.Sprintf("%s/%s.png?size=%d", githubURL, owner, 2*avatarSize)
+				}
+			}
+			cells = append(cells, cell)
+		}
+		data.Rows = append(data.Rows, struct{ Cells []htmlCell }{cells})
+	}
+	return htmlTemplate.Execute(w, data)
+}
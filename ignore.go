@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// defaultIgnoreFile is read from the working directory when --ignore-file
+// isn't given.
+const defaultIgnoreFile = ".topdepignore"
+
+// ignorePatterns are the patterns loaded from the ignore file. Dependents
+// matching any of them are always filtered out.
+var ignorePatterns []string
+
+// loadIgnoreFile reads the patterns in the ignore file name, one per line.
+// Blank lines and lines starting with # are skipped. If optional is set, a
+// missing file is not an error.
+func loadIgnoreFile(name string, optional bool) ([]string, error) {
+	f, err := os.Open(name)
+	if optional && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Repository URLs are accepted as well as owner/name.
+		pattern := strings.TrimPrefix(strings.TrimPrefix(line, "https://"), "github.com/")
+		pattern = strings.ToLower(strings.Trim(pattern, "/"))
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", line, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, scanner.Err()
+}
+
+// ignoredBy returns the ignore pattern matching repo, or "" if there is none.
+// Patterns containing a slash match owner/name, the others match the owner.
+// Both are compared case-insensitively.
+func ignoredBy(repo Repo) string {
+	fullName := strings.ToLower(repoFullName(repo.URL))
+	owner := strings.ToLower(repoOwner(repo.URL))
+	for _, pattern := range ignorePatterns {
+		name := owner
+		if strings.Contains(pattern, "/") {
+			name = fullName
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return pattern
+		}
+	}
+	return ""
+}
@@ -1,27 +1,61 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
 	"net/http"
+	neturl "net/url"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/jedib0t/go-pretty/progress"
-	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
 )
 
 const (
-	githubURL     = "https://github.com"
-	itemSelector  = "#dependents > .Box > div[data-test-id='dg-repo-pkg-dependent']"
-	repoSelector  = "a[data-hovercard-type='repository']"
-	starsSelector = "div:last-child > span:nth-child(1)"
-	forksSelector = "div:last-child > span:nth-child(2)"
+	githubURL          = "https://github.com"
+	dependentsSelector = "#dependents"
+	itemSelector       = "#dependents > .Box > div[data-test-id='dg-repo-pkg-dependent']"
+	repoSelector       = "a[data-hovercard-type='repository']"
+	countsSelector     = "div:last-child > span"
+	starIconSelector   = "svg.octicon-star"
+	forkIconSelector   = "svg.octicon-repo-forked"
+	starsSelector      = "span:nth-child(1)"
+	forksSelector      = "span:nth-child(2)"
+	nextSelector       = "#dependents > div.paginate-container > div > a:contains('Next')"
+	usedBySelector     = "#dependents .table-list-header-toggle a.selected"
+
+	// The link to the next page is found by its rel or its dependents_after
+	// cursor first, so pagination doesn't depend on the English "Next" label
+	// of nextSelector, which is only the last resort.
+	nextRelSelector    = "#dependents > div.paginate-container a[rel~='next']"
+	nextCursorSelector = "#dependents > div.paginate-container a[href*='dependents_after=']"
+
+	// emptyStateSelector matches the message GitHub shows instead of the
+	// list when there are no dependents at all, e.g. "We couldn't find any
+	// repositories depending on this one".
+	emptyStateSelector = ".blankslate"
+
+	// Package dependents list the package name next to the repository, and
+	// the page's package menu shows the ecosystem, e.g. "npm".
+	packageNameSelector = "span.f5 span.color-fg-muted"
+	ecosystemSelector   = "#dependents .select-menu-button"
+
+	// packageMenuItemSelector matches the entries of the package menu, each
+	// linking to the dependents of one package by its package_id.
+	packageMenuItemSelector = "#dependents .select-menu-item"
+
+	// defaultPageSize is the number of dependents GitHub lists per page. The
+	// actual size is taken from the first page; this is only a fallback for
+	// when the first page is empty.
+	defaultPageSize = 30
 )
 
 type Repo struct {
@@ -29,27 +63,249 @@ type Repo struct {
 	URL   string `json:"url"`
 	Stars int    `json:"stars"`
 	Forks int    `json:"forks"`
+
+	// StarsMissing is set when the dependents page showed no star count for
+	// the repo, so Stars is 0 for lack of data rather than a real count.
+	StarsMissing bool `json:"stars_missing,omitempty"`
+
+	// DependentCount is the number of dependents of the repo itself, only
+	// fetched with --depth 2.
+	DependentCount *int `json:"dependent_count,omitempty"`
+
+	// PackageName and Ecosystem are only set for package dependents
+	// (--type package).
+	PackageName string `json:"package_name,omitempty"`
+	Ecosystem   string `json:"ecosystem,omitempty"`
+
+	// NormalizedStars is Stars as a fraction of the most starred matching
+	// repo, only set with --normalize-stars.
+	NormalizedStars *float64 `json:"normalized_stars,omitempty"`
+
+	// Percentile is the percentile rank of Stars among all fetched
+	// dependents, only set with --percentile-column.
+	Percentile *float64 `json:"percentile,omitempty"`
+
+	// Topics are the repo's GitHub topics, only fetched with --topic.
+	Topics []string `json:"topics,omitempty"`
+
+	// Fork tells whether the repo is a fork, only fetched with --only-forks
+	// or --exclude-forks.
+	Fork *bool `json:"fork,omitempty"`
+
+	// CreatedAt is when the repo was created, only fetched with
+	// --created-before or --created-after.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
 }
 
 var (
-	isPackages bool
-	isJSON     bool
-	rows       int
-	minStar    int
+	dependentType string
+	isPackages    bool
+	packageID     string
+	isJSON        bool
+	format        string
+	outputFiles   []string
+	fromFile      string
+	parseOnly     bool
+	htmlFile      string
+	merge         bool
+	includeMeta   bool
+	includeEmpty  bool
+	jsonKeyed     bool
+	stream        bool
+	rows          int
+	minStar       int
+	maxStars      int
+	zeroStar      bool
+	sortKey       string
+	thenBy        string
+
+	tableStyleName    string
+	humanNumbers      bool
+	avatars           bool
+	normalizeStars    bool
+	percentileCol     bool
+	histogram         bool
+	precision         int
+	columnHeaders     map[string]string
+	columnsOrder      []string
+	fields            []string
+	onlyMatching      bool
+	shortNames        bool
+	maxNameCollisions int
+	collapsible       bool
+	summaryText       string
+	nameWidth         int
+
+	topics            []string
+	includeSelf       bool
+	excludeSelf       bool
+	onlyForks         bool
+	excludeForks      bool
+	createdBefore     string
+	createdAfter      string
+	excludeOwner      bool
+	excludeOwnersList []string
+	ignoreFile        string
+	filterExprText    string
+
+	sampleSize     int
+	maxPerOwner    int
+	collapsePkgs   bool
+	requireStars   bool
+	autoType       bool
+	sampleWeighted bool
+	seed           int64
+
+	maxRateLimitWait time.Duration
+	minDelay         time.Duration
+	maxDelay         time.Duration
+	maxIdleConns     int
+	disableHTTP2     bool
+	concurrency      int
+	maxHostConns     int
+	verbose          bool
+	quiet            bool
+	stripANSI        bool
+	progressInterval int
+	retriesPerPage   int
+	retryBudget      int
+	token            string
+	accurate         bool
+	minStarsFromAPI  bool
+	maxPages         int
+	maxRepos         int
+	startPage        int
+	seedCursors      []string
+	bestEffort       bool
+	dedupMode        string
+	bloomFPRate      float64
+	firstPageOnly    bool
+	headerFlags      []string
+	cookie           string
+	cookieFile       string
+	depth            int
+	maxLookups       int
+	failUnder        int
+	resolveRedirects bool
+	errorsToStdout   bool
+	explain          bool
 )
 
+// outputFormats lists the values accepted by --format.
+var outputFormats = []string{"table", "json", "ndjson", "urls", "top", "csv", "html", "badge", "badges", "markdown", "xlsx", "sqlite"}
+
 var rootCmd = &cobra.Command{
-	Use:   "topdep [flags] URL",
+	Use:   "topdep [flags] URL...",
 	Short: "CLI tool for sorting dependent repositories by stars",
-	Args:  cobra.ExactArgs(1),
-	Run:   run,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if fromFile != "" || parseOnly || htmlFile != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	Run: run,
 }
 
 func init() {
-	rootCmd.Flags().BoolVar(&isPackages, "packages", false, "Sort packages instead of repositories")
-	rootCmd.Flags().BoolVar(&isJSON, "json", false, "Output as JSON")
+	rootCmd.Flags().StringVar(&dependentType, "type", typeRepository, "Type of dependents to list: "+strings.Join(dependentTypes, " or "))
+	rootCmd.Flags().BoolVar(&isPackages, "packages", false, "List package dependents (deprecated alias for --type package)")
+	rootCmd.Flags().MarkDeprecated("packages", "use --type package instead")
+	rootCmd.MarkFlagsMutuallyExclusive("type", "packages")
+	rootCmd.Flags().BoolVar(&autoType, "auto-type", false, "If there are no dependents of the requested type, list those of the other type (repositories or packages)")
+	rootCmd.Flags().StringVar(&packageID, "package-id", "", "Only list dependents of this package, for repositories publishing several")
+	rootCmd.Flags().BoolVar(&isJSON, "json", false, "Output as JSON (shorthand for --format json)")
+	rootCmd.Flags().StringVar(&format, "format", "table", "Output format, or comma-separated formats: "+strings.Join(outputFormats, ", "))
+	rootCmd.Flags().StringSliceVarP(&outputFiles, "output-file", "o", nil, "Write the output to a file instead of stdout, one per --format in order")
+	rootCmd.Flags().StringVar(&fromFile, "from-file", "", "Read dependents saved by the dump command instead of crawling GitHub")
+	rootCmd.Flags().BoolVar(&parseOnly, "parse-only", false, "Parse a saved dependents page read from stdin instead of crawling GitHub")
+	rootCmd.Flags().StringVar(&htmlFile, "html-file", "", "Parse this saved dependents page instead of crawling GitHub (implies --parse-only)")
+	rootCmd.MarkFlagsMutuallyExclusive("from-file", "parse-only")
+	rootCmd.MarkFlagsMutuallyExclusive("from-file", "html-file")
+	rootCmd.Flags().BoolVar(&merge, "merge", false, "Combine the dependents of all given URLs into one ranked list")
+	rootCmd.Flags().BoolVar(&jsonKeyed, "json-keyed", false, "With --format json, output an object keyed by repository URL instead of an array")
+	rootCmd.Flags().BoolVar(&includeEmpty, "include-empty", false, "Include optional fields in JSON output even when they weren't fetched")
+	rootCmd.Flags().BoolVar(&includeMeta, "include-meta", false, "Wrap JSON output in an object with crawl metadata")
+	rootCmd.Flags().BoolVar(&errorsToStdout, "errors-stdout", false, "Write JSON errors to stdout instead of stderr in machine-readable formats")
+	rootCmd.Flags().BoolVar(&stream, "stream", false, "Write matching repositories as they are found instead of sorting them (ndjson, urls and csv formats)")
 	rootCmd.Flags().IntVar(&rows, "rows", 10, "Number of repositories to show in output")
 	rootCmd.Flags().IntVar(&minStar, "minstar", 5, "Minimum number of stars")
+	rootCmd.Flags().IntVar(&maxStars, "max-stars", 0, "Maximum number of stars, inclusive (0 for no limit)")
+	rootCmd.Flags().BoolVar(&zeroStar, "include-zero-star", false, "Include dependents of any star count (same as --minstar 0)")
+	rootCmd.MarkFlagsMutuallyExclusive("minstar", "include-zero-star")
+	rootCmd.Flags().StringVar(&sortKey, "sort", "stars", "Sort by: "+strings.Join(sortKeys, ", "))
+	rootCmd.Flags().StringVar(&thenBy, "then-by", "", "Secondary sort key for dependents ranked equal by --sort: "+strings.Join(sortKeys, ", "))
+	rootCmd.Flags().IntVar(&depth, "depth", 1, "Set to 2 to also fetch how many dependents each shown repository has")
+	rootCmd.Flags().IntVar(&maxLookups, "max-dependent-lookups", 500, "Most matching packages --sort dependents looks up the dependents of (0 for no limit)")
+	rootCmd.Flags().BoolVar(&resolveRedirects, "resolve-redirects", false, "Update renamed or transferred repositories to their current URL and name")
+	rootCmd.Flags().BoolVar(&explain, "explain", false, "Print why dependents were filtered out to stderr")
+	rootCmd.Flags().IntVar(&failUnder, "fail-under", 0, "Exit with code 2 if fewer than N dependents match the filters")
+	rootCmd.Flags().BoolVar(&humanNumbers, "human-numbers", false, "Abbreviate star and fork counts in table output (e.g. 12.3k)")
+	rootCmd.Flags().StringVar(&tableStyleName, "table-style", "light", "Table style: "+strings.Join(tableStyleNames, ", "))
+	rootCmd.Flags().BoolVar(&collapsible, "collapsible", false, "With --format markdown, wrap the table in a collapsed <details> block")
+	rootCmd.Flags().StringVar(&summaryText, "summary-text", "Used by", "Title of the --collapsible block")
+	rootCmd.Flags().BoolVar(&avatars, "avatars", false, "Show owner avatars linking to their profiles in HTML output")
+	rootCmd.Flags().BoolVar(&normalizeStars, "normalize-stars", false, "Add each dependent's stars as a fraction of the most starred matching dependent")
+	rootCmd.Flags().BoolVar(&percentileCol, "percentile-column", false, "Add each shown dependent's star percentile among all fetched dependents")
+	rootCmd.Flags().BoolVar(&histogram, "histogram", false, "Print a histogram of the stars of all fetched dependents to stderr")
+	rootCmd.Flags().IntVar(&precision, "precision", 1, "Decimal places for derived metrics and --human-numbers in table output")
+	rootCmd.Flags().StringSliceVar(&fields, "fields", nil, "Only show these columns, e.g. name,url,stars (default is all columns for the given flags)")
+	rootCmd.Flags().StringSliceVar(&columnsOrder, "columns-order", nil, "Put these columns first, in this order, e.g. stars,name,url,forks")
+	rootCmd.Flags().StringToStringVar(&columnHeaders, "column-names", nil, "Rename table columns, e.g. name=Repository,stars=★")
+	rootCmd.Flags().IntVar(&nameWidth, "name-width", 50, "Truncate names in the table to this width, with an ellipsis (0 for no limit)")
+	rootCmd.Flags().BoolVar(&shortNames, "short-names", false, "Show bare repository names instead of owner/name in the table")
+	rootCmd.Flags().IntVar(&maxNameCollisions, "max-name-collisions", 0, "With --short-names, warn when more shown dependents than this share their name with another one")
+	rootCmd.Flags().BoolVar(&onlyMatching, "only-matching", false, "Only report dependents matching the star criteria in the summary")
+	rootCmd.Flags().BoolVar(&excludeOwner, "exclude-owner", false, "Exclude dependents owned by the owner of the queried repository")
+	rootCmd.Flags().StringSliceVar(&topics, "topic", nil, "Only include dependents with any of these GitHub topics, looked up with the API")
+	rootCmd.Flags().BoolVar(&includeSelf, "include-self", false, "Keep the queried repository when it is listed as its own dependent")
+	rootCmd.Flags().BoolVar(&excludeSelf, "exclude-self", false, "Drop the queried repository when it is listed as its own dependent (default)")
+	rootCmd.MarkFlagsMutuallyExclusive("include-self", "exclude-self")
+	rootCmd.Flags().BoolVar(&onlyForks, "only-forks", false, "Only include dependents that are forks, looked up with the API")
+	rootCmd.Flags().BoolVar(&excludeForks, "exclude-forks", false, "Exclude dependents that are forks, looked up with the API")
+	rootCmd.MarkFlagsMutuallyExclusive("only-forks", "exclude-forks")
+	rootCmd.Flags().StringVar(&createdBefore, "created-before", "", "Only include dependents created before this date, e.g. 2020-01-01 or 1y for a year ago, looked up with the API")
+	rootCmd.Flags().StringVar(&createdAfter, "created-after", "", "Only include dependents created on or after this date, e.g. 2024-06 or 30d for 30 days ago, looked up with the API")
+	rootCmd.Flags().StringSliceVar(&excludeOwnersList, "exclude-owners", nil, "Exclude dependents owned by any of these users or organizations")
+	rootCmd.Flags().StringVar(&configFile, "config", "", "File of flag defaults, one \"flag = value\" per line (default is topdep/config in the user config directory if present)")
+	rootCmd.Flags().StringVar(&ignoreFile, "ignore-file", "", "File of owners and owner/name glob patterns to always exclude (default is "+defaultIgnoreFile+" if present)")
+	rootCmd.Flags().StringVar(&filterExprText, "filter-expr", "", "Only keep dependents matching this expression, e.g. \"stars>100 && forks<50 && owner!=mycorp\"")
+	rootCmd.Flags().BoolVar(&requireStars, "require-stars", false, "Leave out dependents the dependents page shows no star count for, instead of counting them as 0 stars")
+	rootCmd.Flags().BoolVar(&collapsePkgs, "collapse-monorepo-packages", false, "With --type package, list each repository once instead of once per package it publishes")
+	rootCmd.Flags().IntVar(&maxPerOwner, "max-per-owner", 0, "Show at most this many repositories of the same owner, for a more diverse list (0 for no limit)")
+	rootCmd.Flags().IntVar(&sampleSize, "sample", 0, "Show a random sample of N matching repositories instead of the top ones")
+	rootCmd.Flags().BoolVar(&sampleWeighted, "sample-weighted", false, "Weight --sample by star count")
+	rootCmd.Flags().Int64Var(&seed, "seed", 0, "Random seed for --sample and --sort random (default: random)")
+	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Log details about the crawl to stderr")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Don't print progress or the summary to stderr")
+	rootCmd.Flags().BoolVar(&stripANSI, "strip-ansi", false, "Never write ANSI escape sequences, e.g. when a redirected stderr is taken for a terminal")
+	rootCmd.Flags().IntVar(&progressInterval, "progress-interval", 0, "Print a status line every N pages instead of a progress bar, even with --quiet (0 to disable)")
+	rootCmd.Flags().Int64Var(&maxPageBytes, "max-page-bytes", defaultMaxPageBytes, "Parse at most this many bytes of each page, 0 for no limit")
+	rootCmd.Flags().IntVar(&maxIdleConns, "max-idle-conns", 10, "Maximum number of idle connections kept open to GitHub")
+	rootCmd.Flags().BoolVar(&disableHTTP2, "disable-http2", false, "Force HTTP/1.1 instead of negotiating HTTP/2")
+	rootCmd.Flags().IntVar(&concurrency, "concurrency", 4, "Number of concurrent requests when enriching repositories")
+	rootCmd.Flags().IntVar(&maxHostConns, "max-host-conns", 4, "Maximum number of requests in flight to each host, whatever --concurrency is (0 for no limit)")
+	rootCmd.Flags().IntVar(&retriesPerPage, "retries-per-page", 3, "Number of times a page is retried after a network or server error before the crawl fails")
+	rootCmd.Flags().StringArrayVar(&headerFlags, "header", nil, "Header set on every request for a github.com page, as \"Key: Value\" (repeatable)")
+	rootCmd.Flags().IntVar(&startPage, "start-page", 1, "Start the crawl at this dependents page, skipping the ones before it")
+	rootCmd.Flags().IntVar(&maxPages, "max-pages", 0, "Stop the crawl after this many dependents pages (0 for no limit)")
+	rootCmd.Flags().BoolVar(&bestEffort, "best-effort", false, "If the crawl fails after the first page, report the dependents fetched so far with a warning instead of exiting")
+	rootCmd.Flags().StringArrayVar(&seedCursors, "seed-cursor", nil, "Also crawl from this dependents page URL or dependents_after cursor to the end, merging the results (repeatable)")
+	rootCmd.Flags().IntVar(&maxRepos, "max-repos", 1000000, "Stop the crawl after this many dependents, to bound memory use (0 for no limit)")
+	rootCmd.Flags().StringVar(&dedupMode, "dedup-mode", "exact", "How dependents listed on several pages are recognized: exact, or bloom to bound memory at the cost of rarely dropping one")
+	rootCmd.Flags().Float64Var(&bloomFPRate, "bloom-fp-rate", 0.01, "With --dedup-mode bloom, the rate of new dependents mistaken for already seen ones")
+	rootCmd.Flags().BoolVar(&firstPageOnly, "first-page-only", false, "Only crawl the first dependents page, for a quick look (same as --max-pages 1)")
+	rootCmd.MarkFlagsMutuallyExclusive("max-pages", "first-page-only")
+	rootCmd.Flags().StringVar(&token, "token", "", "GitHub token for options using the GitHub API (default is $GITHUB_TOKEN)")
+	rootCmd.Flags().BoolVar(&accurate, "accurate", false, "Replace the abbreviated star and fork counts with exact ones from the GitHub GraphQL API (requires a token)")
+	rootCmd.Flags().DurationVar(&repoCacheTTL, "repo-cache-ttl", 0, "Reuse details of dependents looked up with the API by runs within this time, e.g. 24h (default is no cache)")
+	rootCmd.Flags().BoolVar(&minStarsFromAPI, "min-stars-from-api", false, "With --accurate, look up every fetched dependent so --minstar uses exact counts")
+	rootCmd.Flags().StringVar(&cookie, "cookie", "", "Cookie header sent with every request, e.g. from a logged-in browser session")
+	rootCmd.Flags().StringVar(&cookieFile, "cookie-file", "", "Read the Cookie header sent with every request from this file")
+	rootCmd.MarkFlagsMutuallyExclusive("cookie", "cookie-file")
+	rootCmd.Flags().IntVar(&retryBudget, "retry-budget", 0, "Total number of retries allowed across all pages before the crawl fails (0 for no limit)")
+	rootCmd.Flags().DurationVar(&maxRateLimitWait, "max-rate-limit-wait", 15*time.Minute, "Maximum total time to pause for GitHub rate limits before giving up")
+	rootCmd.Flags().DurationVar(&minDelay, "min-delay", 0, "Minimum random pause between dependents pages")
+	rootCmd.Flags().DurationVar(&maxDelay, "max-delay", 0, "Maximum random pause between dependents pages (default is --min-delay)")
 }
 
 func main() {
@@ -60,155 +316,1233 @@ func main() {
 }
 
 func run(cmd *cobra.Command, args []string) {
-	url := args[0]
+	configPath := configFile
+	if configPath == "" {
+		configPath = defaultConfigFile()
+	}
+	if configPath != "" {
+		if err := applyConfig(cmd, configPath, configFile == ""); err != nil {
+			exitWithError(1, "Error loading config file %s: %v", configPath, err)
+		}
+	}
+
+	resolveDependentType()
 
-	repos, err := fetchDependents(url, !isPackages)
+	var urls []string
+	for _, arg := range args {
+		urls = append(urls, normalizeRepoURL(arg))
+	}
+
+	if isJSON {
+		format = "json"
+	}
+	if zeroStar {
+		// Rows without a star count parse as 0 stars, so they are kept too.
+		minStar = 0
+	}
+	if firstPageOnly {
+		maxPages = 1
+	}
+	if err := checkStarRange(minStar, maxStars); err != nil {
+		exitWithError(1, "%v", err)
+	}
+	outputs, err := planOutputs(strings.Split(format, ","), outputFiles)
 	if err != nil {
-		fmt.Printf("Error fetching dependents: %v\n", err)
-		os.Exit(1)
+		exitWithError(1, "%v", err)
+	}
+	// format is the format written to stdout, if any. It decides how errors
+	// are reported.
+	format = outputs[0].format
+	for _, o := range outputs {
+		if o.file == "" {
+			format = o.format
+		}
+	}
+	if len(outputs) == 1 && format == "top" {
+		// Only the top repo is written, so don't enrich any others.
+		rows = 1
+	}
+	if jsonKeyed && !hasFormat(outputs, "json") {
+		exitWithError(1, "--json-keyed requires --format json")
+	}
+	if includeMeta && !hasFormat(outputs, "json") {
+		exitWithError(1, "--include-meta requires --format json")
+	}
+	if !isSortKey(sortKey) {
+		exitWithError(1, "Unknown sort key %q (valid: %s)", sortKey, strings.Join(sortKeys, ", "))
+	}
+	if thenBy != "" && !isSortKey(thenBy) {
+		exitWithError(1, "Unknown --then-by key %q (valid: %s)", thenBy, strings.Join(sortKeys, ", "))
+	}
+	if thenBy != "" && (sortKey == "random" || thenBy == "random") {
+		exitWithError(1, "--then-by can't be random or be used with --sort random")
+	}
+	if sortsByDependents() && dependentType != typePackage {
+		exitWithError(1, "Sorting by dependents requires --type package")
+	}
+	if !isTableStyle(tableStyleName) {
+		exitWithError(1, "Unknown table style %q (valid: %s)", tableStyleName, strings.Join(tableStyleNames, ", "))
+	}
+	if progressInterval < 0 {
+		exitWithError(1, "--progress-interval can't be negative")
+	}
+	if precision < 0 {
+		exitWithError(1, "--precision can't be negative")
+	}
+	if !isDedupMode(dedupMode) {
+		exitWithError(1, "Unknown dedup mode %q (valid: %s)", dedupMode, strings.Join(dedupModes, ", "))
+	}
+	if bloomFPRate <= 0 || bloomFPRate >= 1 {
+		exitWithError(1, "--bloom-fp-rate must be between 0 and 1")
+	}
+	if collapsePkgs && (dependentType != typePackage || stream) {
+		exitWithError(1, "--collapse-monorepo-packages requires --type package and can't be used with --stream")
+	}
+	if startPage < 1 {
+		exitWithError(1, "--start-page must be at least 1")
+	}
+	if maxPerOwner < 0 {
+		exitWithError(1, "--max-per-owner can't be negative")
+	}
+	if maxPerOwner > 0 && sampleSize > 0 {
+		exitWithError(1, "--max-per-owner can't be used with --sample")
+	}
+	if maxPageBytes < 0 {
+		exitWithError(1, "--max-page-bytes can't be negative")
+	}
+	if minDelay < 0 || maxDelay < 0 {
+		exitWithError(1, "--min-delay and --max-delay can't be negative")
+	}
+	if maxDelay == 0 {
+		maxDelay = minDelay
+	}
+	if maxDelay < minDelay {
+		exitWithError(1, "--max-delay can't be lower than --min-delay")
+	}
+	if maxLookups < 0 {
+		exitWithError(1, "--max-dependent-lookups can't be negative")
+	}
+	if depth != 1 && depth != 2 {
+		exitWithError(1, "--depth must be 1 or 2")
+	}
+	if fromFile != "" && (stream || excludeOwner) {
+		exitWithError(1, "--stream and --exclude-owner need a URL and can't be used with --from-file")
+	}
+	if (parseOnly || htmlFile != "") && (stream || excludeOwner || len(seedCursors) > 0) {
+		exitWithError(1, "--stream, --exclude-owner and --seed-cursor need a URL and can't be used with --parse-only")
+	}
+	for _, o := range outputs {
+		if len(urls) > 1 && !merge && o.format != "table" && o.format != "ndjson" && o.format != "urls" && o.format != "sqlite" {
+			exitWithError(1, "--format %s needs --merge when querying several URLs", o.format)
+		}
+	}
+	if merge && autoType {
+		exitWithError(1, "--auto-type can't be used with --merge")
+	}
+	if merge && stream {
+		exitWithError(1, "--stream can't be used with --merge")
+	}
+	if len(seedCursors) > 0 && (stream || fromFile != "" || len(urls) > 1) {
+		exitWithError(1, "--seed-cursor needs a single URL and can't be used with --stream or --from-file")
+	}
+	if stream && (len(outputs) > 1 || format != "ndjson" && format != "urls" && format != "csv") {
+		exitWithError(1, "--stream requires --format ndjson, urls or csv")
+	}
+	if (collapsible || cmd.Flags().Changed("summary-text")) && !hasFormat(outputs, "markdown") {
+		exitWithError(1, "--collapsible and --summary-text require --format markdown")
+	}
+	if avatars && !hasFormat(outputs, "html") {
+		exitWithError(1, "--avatars requires --format html")
+	}
+	if stream && (len(topics) > 0 || onlyForks || excludeForks || createdBefore != "" || createdAfter != "") {
+		exitWithError(1, "--topic, --only-forks, --exclude-forks, --created-before and --created-after can't be used with --stream")
+	}
+	now := time.Now()
+	if createdBefore != "" {
+		if createdBeforeTime, err = parseDate(createdBefore, now); err != nil {
+			exitWithError(1, "Invalid --created-before: %v", err)
+		}
+	}
+	if createdAfter != "" {
+		if createdAfterTime, err = parseDate(createdAfter, now); err != nil {
+			exitWithError(1, "Invalid --created-after: %v", err)
+		}
+	}
+	if createdBefore != "" && createdAfter != "" && !createdAfterTime.Before(createdBeforeTime) {
+		exitWithError(1, "--created-after must be earlier than --created-before")
+	}
+	if stream && (normalizeStars || percentileCol) {
+		exitWithError(1, "--normalize-stars and --percentile-column need all dependents and can't be used with --stream")
+	}
+	if err := checkStreamLookups(); err != nil {
+		exitWithError(1, "%v", err)
 	}
 
-	sortedRepos := sortRepos(repos, rows, minStar)
+	for _, key := range fields {
+		if !isTableColumn(key) {
+			exitWithError(1, "Unknown column %q in --fields (valid: %s)", key, strings.Join(columnKeys(), ", "))
+		}
+	}
+	for _, key := range columnsOrder {
+		if !isTableColumn(key) {
+			exitWithError(1, "Unknown column %q in --columns-order (valid: %s)", key, strings.Join(columnKeys(), ", "))
+		}
+	}
+	for key := range columnHeaders {
+		if !isTableColumn(key) {
+			exitWithError(1, "Unknown column %q in --column-names (valid: %s)", key, strings.Join(columnKeys(), ", "))
+		}
+	}
 
-	if isJSON {
-		displayJSON(sortedRepos)
+	headers, err := parseHeaders(headerFlags)
+	if err != nil {
+		exitWithError(1, "Invalid --header: %v", err)
+	}
+	if cookieFile != "" {
+		data, err := os.ReadFile(cookieFile)
+		if err != nil {
+			exitWithError(1, "Error reading cookie file: %v", err)
+		}
+		cookie = strings.TrimSpace(string(data))
+	}
+	if cookie != "" && headers.Get("Cookie") == "" {
+		// A Cookie given with --header takes precedence.
+		headers.Set("Cookie", cookie)
+	}
+	requestHeaders = headers
+	if token == "" {
+		// Not the flag's default, so the token isn't shown in --help.
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+	if accurate && token == "" {
+		exitWithError(1, "--accurate requires --token or GITHUB_TOKEN, the GraphQL API doesn't allow anonymous requests")
+	}
+	if minStarsFromAPI && !accurate {
+		exitWithError(1, "--min-stars-from-api requires --accurate")
+	}
+	if accurate && stream {
+		exitWithError(1, "--accurate can't be used with --stream")
+	}
+	if repoCacheTTL < 0 {
+		exitWithError(1, "--repo-cache-ttl can't be negative")
+	}
+	if repoCacheTTL > 0 {
+		if path := defaultRepoCacheFile(); path != "" {
+			if err := loadRepoCache(path); err != nil {
+				// A broken cache only costs API requests.
+				fmt.Fprintf(os.Stderr, "Warning: ignoring the repo cache %s: %v\n", path, err)
+				repoCache.entries = make(map[string]repoCacheEntry)
+			}
+		}
+	}
+
+	ignorePath := ignoreFile
+	if ignorePath == "" {
+		ignorePath = defaultIgnoreFile
+	}
+	patterns, err := loadIgnoreFile(ignorePath, ignoreFile == "")
+	if err != nil {
+		exitWithError(1, "Error loading ignore file %s: %v", ignorePath, err)
+	}
+	ignorePatterns = patterns
+
+	if filterExprText != "" {
+		filterExpr, err = parseFilterExpr(filterExprText)
+		if err != nil {
+			exitWithError(1, "Invalid --filter-expr: %v", err)
+		}
+	}
+
+	for i, o := range outputs {
+		if o.file == "" {
+			outputs[i].w = os.Stdout
+			continue
+		}
+		if o.format == "sqlite" {
+			// The database is opened by displaySQLite, adding to it
+			// rather than overwriting it.
+			continue
+		}
+		f, err := os.Create(o.file)
+		if err != nil {
+			exitWithError(1, "Error creating output file: %v", err)
+		}
+		defer f.Close()
+		outputs[i].w = f
+	}
+	if stripANSI {
+		for i := range outputs {
+			outputs[i].w = ansiStripper{outputs[i].w}
+		}
+	}
+
+	httpClient = newHTTPClient(maxIdleConns, disableHTTP2)
+
+	if fromFile != "" {
+		repos, err := loadRepos(fromFile)
+		if err != nil {
+			exitWithError(1, "Error loading %s: %v", fromFile, err)
+		}
+		report(cmd, outputs, repos, crawlStats{}, excludeOwnersList)
+		return
+	}
+
+	if parseOnly || htmlFile != "" {
+		in, name := io.Reader(os.Stdin), "stdin"
+		if htmlFile != "" {
+			f, err := os.Open(htmlFile)
+			if err != nil {
+				exitWithError(1, "Error opening %s: %v", htmlFile, err)
+			}
+			defer f.Close()
+			in, name = f, htmlFile
+		}
+		repos, err := parseDependentsPage(in, name, dependentType)
+		if err != nil {
+			exitWithFetchError(err)
+		}
+		report(cmd, outputs, repos, crawlStats{Pages: 1}, excludeOwnersList)
+		return
+	}
+
+	if merge {
+		var (
+			all   []Repo
+			stats crawlStats
+		)
+		// Set before crawling, so the crawl counts the same dependents as
+		// matching as the report.
+		selfRepos = urls
+		for _, url := range urls {
+			repos, urlStats := crawl(url, nil, nil)
+			all = append(all, repos...)
+			stats.Pages += urlStats.Pages
+			stats.UsedBy += urlStats.UsedBy
+			stats.Duration += urlStats.Duration
+			if stats.StoppedBy == "" {
+				stats.StoppedBy = urlStats.StoppedBy
+			}
+		}
+		report(cmd, outputs, mergeRepos(all), stats, ownersToExclude(urls))
+		return
+	}
+
+	requestedType := dependentType
+	for i, url := range urls {
+		dependentType = requestedType
+		for _, o := range outputs {
+			if len(urls) > 1 && o.format == "table" {
+				if i > 0 {
+					fmt.Fprintln(o.w)
+				}
+				fmt.Fprintln(o.w, url)
+			}
+		}
+		owners := ownersToExclude([]string{url})
+		selfRepos = []string{url}
+		repos, stats := crawl(url, outputs[0].w, owners)
+		report(cmd, outputs, repos, stats, owners)
+	}
+}
+
+// ownersToExclude returns the owners given with --exclude-owners and, with
+// --exclude-owner, the owners of the queried urls.
+func ownersToExclude(urls []string) []string {
+	owners := append([]string(nil), excludeOwnersList...)
+	if excludeOwner {
+		for _, url := range urls {
+			owners = append(owners, repoOwner(url))
+		}
+	}
+	return owners
+}
+
+// crawl fetches the dependents of url. With --stream, the ones passing the
+// filters are also written to out while crawling.
+func crawl(url string, out io.Writer, owners []string) ([]Repo, crawlStats) {
+	// In stream mode, matching repos are written by a separate goroutine
+	// as fetchDependents finds them.
+	var found chan Repo
+	streamed := make(chan error, 1)
+	if stream {
+		found = make(chan Repo)
+		go func() {
+			streamed <- streamRepos(out, found, rows, func(repo Repo) bool {
+				return keepRepo(repo, minStar, owners)
+			})
+		}()
+	}
+
+	repos, stats, err := fetchDependents(url, dependentType, found, nil)
+	if err == nil && len(repos) == 0 && autoType {
+		// The other type is shown from here on, with its columns.
+		other := typePackage
+		if dependentType == typePackage {
+			other = typeRepository
+		}
+		fmt.Fprintf(os.Stderr, "No %s dependents found, listing %s dependents instead (--auto-type)\n", dependentType, other)
+		dependentType = other
+		repos, stats, err = fetchDependents(url, dependentType, found, nil)
+	}
+	if err != nil {
+		checkPartialCrawl(err, stats, len(repos))
+	}
+	if len(seedCursors) > 0 {
+		// Each seed is crawled to the end too, picking up dependents that
+		// shifting pagination made the first crawl skip.
+		for _, seed := range seedCursors {
+			seedRepos, seedStats, err := fetchDependentsFrom(seedPageURL(url, seed), false, dependentType, nil, nil)
+			if err != nil {
+				checkPartialCrawl(err, seedStats, len(seedRepos))
+				stats.StoppedBy = truncatedByError
+			}
+			repos = append(repos, seedRepos...)
+			stats.Pages += seedStats.Pages
+			stats.Duration += seedStats.Duration
+		}
+		repos = mergeRepos(repos)
+		logVerbose("Seed cursors brought the crawl to %d distinct dependents", len(repos))
+	}
+	if stream {
+		close(found)
+		if err := <-streamed; err != nil {
+			exitWithError(1, "Error writing output: %v", err)
+		}
+	}
+	return repos, stats
+}
+
+// checkPartialCrawl exits with err, unless --best-effort is set and the crawl
+// fetched at least one page before failing. Then it warns that only the
+// fetched dependents are reported.
+func checkPartialCrawl(err error, stats crawlStats, fetched int) {
+	if !bestEffort || stats.Pages == 0 {
+		exitWithFetchError(err)
+	}
+	fmt.Fprintf(os.Stderr, "Warning: the crawl failed after %d pages, showing the %d dependents fetched so far: %v\n", stats.Pages, fetched, err)
+}
+
+// report filters, sorts and writes repos to each of outputs. In stream mode
+// the repos have already been written and only the summary is reported.
+func report(cmd *cobra.Command, outputs []output, repos []Repo, stats crawlStats, owners []string) {
+	var err error
+	if collapsePkgs {
+		repos = collapsePackages(repos)
+	}
+	if minStarsFromAPI {
+		// All fetched repos are looked up, so that the star filters
+		// use exact counts too.
+		repos, err = fetchExactCounts(repos)
+		if err != nil {
+			exitWithFetchError(err)
+		}
+	}
+
+	matching := filterRepos(repos, minStar, owners)
+	if len(owners) > 0 && !quiet {
+		excluded := len(filterRepos(repos, minStar, nil)) - len(matching)
+		fmt.Fprintf(os.Stderr, "Excluded %d dependents owned by %s\n", excluded, strings.Join(owners, ", "))
+	}
+
+	if !quiet && format != "badge" {
+		fmt.Fprintf(os.Stderr, "Distinct owners among matching dependents: %d\n", Results(matching).Summary().Owners)
+	}
+	if missing := countStarsMissing(repos); missing > 0 && !quiet && format != "badge" {
+		if requireStars {
+			fmt.Fprintf(os.Stderr, "Left out %d dependents without a star count (--require-stars)\n", missing)
+		} else {
+			fmt.Fprintf(os.Stderr, "Dependents without a star count, counted as 0 stars: %d\n", missing)
+		}
+	}
+	if histogram && !(hasFormat(outputs, "json") && includeMeta) {
+		displayHistogram(os.Stderr, starHistogram(repos))
+	}
+	if stream {
+		if explain {
+			fmt.Fprintln(os.Stderr, "Filtered out dependents:")
+			explainFiltered(os.Stderr, repos, nil, minStar, owners, explainLimit)
+		}
+		checkFailUnder(len(matching))
+		return
+	}
+
+	if accurate && !minStarsFromAPI {
+		matching, err = fetchExactCounts(matching)
+		if err != nil {
+			exitWithFetchError(err)
+		}
+	}
+	// apiFiltered are the repos dropped by the filters looked up with the
+	// API, as enriched for them, for --explain.
+	var apiFiltered []Repo
+	if len(topics) > 0 {
+		// Topics are only looked up for repos passing the other filters,
+		// to keep the number of API requests down.
+		matching, err = enrichRepos(matching, concurrency, fetchTopics)
+		if err != nil {
+			exitWithFetchError(err)
+		}
+		kept := filterByTopics(matching, topics)
+		apiFiltered = append(apiFiltered, droppedRepos(matching, kept)...)
+		matching = kept
+	}
+	if onlyForks || excludeForks {
+		matching, err = enrichRepos(matching, concurrency, fetchFork)
+		if err != nil {
+			exitWithFetchError(err)
+		}
+		kept := filterForks(matching, onlyForks)
+		apiFiltered = append(apiFiltered, droppedRepos(matching, kept)...)
+		matching = kept
+	}
+	if createdBefore != "" || createdAfter != "" {
+		matching, err = enrichRepos(matching, concurrency, fetchCreatedAt)
+		if err != nil {
+			exitWithFetchError(err)
+		}
+		kept := filterByCreated(matching, createdBeforeTime, createdAfterTime)
+		apiFiltered = append(apiFiltered, droppedRepos(matching, kept)...)
+		matching = kept
+	}
+	if explain {
+		fmt.Fprintln(os.Stderr, "Filtered out dependents:")
+		explainFiltered(os.Stderr, repos, apiFiltered, minStar, owners, explainLimit)
+	}
+
+	if normalizeStars {
+		normalizeRepoStars(matching)
+	}
+
+	if sortsByDependents() {
+		// Sorting needs the dependent count of every matching package,
+		// not only the shown ones as with --depth 2.
+		if err := checkDependentLookups(len(matching)); err != nil {
+			exitWithError(1, "%v", err)
+		}
+		matching, err = enrichRepos(matching, concurrency, func(repo Repo) (Repo, error) {
+			return fetchDependentCount(repo, dependentType)
+		})
+		if err != nil {
+			exitWithFetchError(err)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	if cmd.Flags().Changed("seed") {
+		rng = rand.New(rand.NewSource(seed))
+	}
+	sortedRepos := rankRepos(matching, rng)
+	// With --max-per-owner, the truncation note below tells why fewer
+	// are shown.
+	if sampleSize == 0 && len(sortedRepos) < rows && len(sortedRepos) == len(matching) && !quiet && format != "badge" && format != "top" {
+		// Tell whether the list is short because of the filters or
+		// because the crawl didn't get all dependents.
+		var reason string
+		switch filtered := len(repos) - len(matching); {
+		case stats.StoppedBy != "":
+			reason = "the crawl stopped early, so more may exist"
+		case filtered == 0:
+			reason = "that is every dependent GitHub listed"
+		default:
+			reason = fmt.Sprintf("the other %d dependents GitHub listed were filtered out", filtered)
+			if minStar > 0 {
+				reason += "; try a lower --minstar"
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Showing %d of %d matching dependents (requested %d with --rows): %s\n",
+			len(sortedRepos), len(matching), rows, reason)
+	}
+
+	// A failed --best-effort crawl was already warned about.
+	if cause := truncationCause(stats, matching, sortedRepos); cause != "" && cause != truncatedByError && !quiet && format != "badge" && format != "top" {
+		fmt.Fprintf(os.Stderr, "Output truncated by --%s: showing %d of %d matching dependents\n", cause, len(sortedRepos), len(matching))
+	}
+
+	if percentileCol {
+		setStarPercentiles(sortedRepos, repos)
+	}
+
+	if resolveRedirects {
+		resolved, err := enrichRepos(sortedRepos, concurrency, resolveRedirect)
+		if err != nil {
+			exitWithFetchError(err)
+		}
+		// The fetched repos are written too, by --format sqlite, and must
+		// not list a moved repo under its old URL as well.
+		repos = applyRedirects(repos, sortedRepos, resolved)
+		sortedRepos = resolved
+	}
+	if depth == 2 && !sortsByDependents() {
+		sortedRepos, err = enrichRepos(sortedRepos, concurrency, func(repo Repo) (Repo, error) {
+			return fetchDependentCount(repo, dependentType)
+		})
+		if err != nil {
+			exitWithFetchError(err)
+		}
+	}
+
+	if shortNames {
+		if collisions := countNameCollisions(sortedRepos); collisions > maxNameCollisions {
+			fmt.Fprintf(os.Stderr, "Warning: %d of the shown dependents share their name with another one; tell them apart by URL or drop --short-names\n", collisions)
+		}
+	}
+
+	if err := saveRepoCache(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: couldn't save the repo cache: %v\n", err)
+	}
+
+	stdoutFormat := format
+	for _, o := range outputs {
+		// The display functions read the format of the output they write.
+		format = o.format
+		out := o.w
+		switch o.format {
+		case "json":
+			if includeMeta {
+				err = displayJSONWithMeta(out, sortedRepos, newQueryMeta(owners), newCrawlMeta(stats, repos, matching, sortedRepos))
+			} else {
+				err = displayJSON(out, sortedRepos)
+			}
+		case "ndjson", "urls":
+			err = displayLines(out, sortedRepos)
+		case "top":
+			err = displayTop(out, sortedRepos)
+		case "csv":
+			err = displayCSV(out, sortedRepos)
+		case "html":
+			err = displayHTML(out, sortedRepos)
+		case "badge":
+			err = displayBadge(out, len(matching))
+		case "badges":
+			err = displayBadges(out, sortedRepos)
+		case "markdown":
+			err = displayMarkdown(out, sortedRepos)
+		case "xlsx":
+			err = displayXLSX(out, sortedRepos)
+		case "sqlite":
+			err = displaySQLite(o.file, repos, sortedRepos, newQueryMeta(owners), newCrawlMeta(stats, repos, matching, sortedRepos))
+		default:
+			displayTable(out, sortedRepos)
+		}
+		format = stdoutFormat
+		if err != nil {
+			exitWithError(1, "Error writing output: %v", err)
+		}
+	}
+
+	checkFailUnder(len(matching))
+	if len(sortedRepos) == 0 && hasFormat(outputs, "top") {
+		os.Exit(exitNoMatches)
+	}
+}
+
+// checkStreamLookups checks that --stream isn't combined with flags looking
+// up more details of the dependents after the crawl, which it never does.
+func checkStreamLookups() error {
+	if !stream {
+		return nil
+	}
+	if depth == 2 {
+		return errors.New("--depth 2 can't be used with --stream")
+	}
+	if sortsByDependents() {
+		return errors.New("--sort dependents and --then-by dependents need all dependents and can't be used with --stream")
+	}
+	if resolveRedirects {
+		return errors.New("--resolve-redirects can't be used with --stream")
+	}
+	return nil
+}
+
+// rankRepos returns the repos of matching to show: a --sample of them drawn
+// from rng, or otherwise them ordered by --sort and --then-by, with at most
+// --max-per-owner per owner and cut to --rows.
+func rankRepos(matching []Repo, rng *rand.Rand) []Repo {
+	if sampleSize > 0 {
+		return sampleRepos(matching, sampleSize, sampleWeighted, rng)
+	}
+	var sorted Results
+	if sortKey == "random" {
+		sorted = Results(matching).Shuffle(rng)
 	} else {
-		displayTable(sortedRepos)
+		sorted = Results(matching).SortBy(sortKey, thenBy)
+	}
+	return sorted.MaxPerOwner(maxPerOwner).Top(rows)
+}
+
+// checkFailUnder exits with exitFailUnder when fewer than --fail-under repos
+// match the filters.
+func checkFailUnder(matching int) {
+	if failUnder <= 0 {
+		return
 	}
+	if matching < failUnder {
+		fmt.Fprintf(os.Stderr, "Only %d dependents match the filters, --fail-under requires %d\n", matching, failUnder)
+		os.Exit(exitFailUnder)
+	}
+}
+
+// truncation is why not all dependents are in the output.
+type truncation string
+
+const (
+	truncatedByRows     truncation = "rows"
+	truncatedBySample   truncation = "sample"
+	truncatedByMaxPages truncation = "max-pages"
+	truncatedByMaxRepos truncation = "max-repos"
+	truncatedByError    truncation = "error"
+	// truncatedByMaxPerOwner is only the cause when --rows wasn't reached.
+	truncatedByMaxPerOwner truncation = "max-per-owner"
+)
+
+// crawlStats describes a finished crawl.
+type crawlStats struct {
+	Pages    int
+	UsedBy   int
+	Duration time.Duration
+	// StoppedBy is set when the crawl stopped before the last page.
+	StoppedBy truncation
 }
 
-func fetchDependents(url string, isRepositories bool) ([]Repo, error) {
-	dependentType := "REPOSITORY"
-	if !isRepositories {
-		dependentType = "PACKAGE"
+// pagesPerSecond returns the average crawl rate.
+func (s crawlStats) pagesPerSecond() float64 {
+	if s.Duration <= 0 {
+		return 0
 	}
+	return float64(s.Pages) / s.Duration.Seconds()
+}
 
-	pageURL := fmt.Sprintf("%s/network/dependents?dependent_type=%s", url, dependentType)
+// completenessWarning is the completeness ratio below which a crawl is
+// reported as having missed dependents.
+const completenessWarning = 0.9
 
-	var repos []Repo
-	pageCount := 0
-	totalFetched := 0
-	matchingStarCriteria := 0
+// completeness returns the share of the dependents GitHub reports that were
+// fetched. It is unknown when GitHub reports no total.
+func (s crawlStats) completeness(fetched int) (float64, bool) {
+	if s.UsedBy == 0 {
+		return 0, false
+	}
+	return float64(fetched) / float64(s.UsedBy), true
+}
+
+// unfilteredCrawl is set by commands keeping every dependent, such as dump, so
+// that crawl summaries leave out the matching count.
+var unfilteredCrawl bool
 
-	// Initialize progress writer
-	pw := progress.NewWriter()
-	pw.SetUpdateFrequency(time.Millisecond * 100)
-	pw.Style().Colors = progress.StyleColorsExample
+// crawlCounters are the running totals of a crawl. They are safe for
+// concurrent use so that pages can be parsed in parallel.
+type crawlCounters struct {
+	pages    atomic.Int64
+	fetched  atomic.Int64
+	matching atomic.Int64
 
-	// Start the progress writer
-	go pw.Render()
+	// owners are excluded from the matching count, like from the output.
+	owners []string
 
-	// Create a tracker for the progress bar
-	tracker := &progress.Tracker{
-		Message: "Fetching dependents",
-		Total:   100,
-		Units:   progress.UnitsDefault,
+	mu    sync.Mutex
+	seen  dedupSet
+	repos []Repo
+}
+
+// add counts repo, and reports whether it is new to the crawl. It counts as
+// matching if it passes the same filters as the output.
+func (c *crawlCounters) add(repo Repo) bool {
+	c.mu.Lock()
+	if !c.seen.add(dedupKey(repo)) {
+		c.mu.Unlock()
+		return false
 	}
-	pw.AppendTracker(tracker)
+	c.repos = append(c.repos, repo)
+	c.mu.Unlock()
 
-	for {
-		resp, err := http.Get(pageURL)
+	c.fetched.Add(1)
+	if keepRepo(repo, minStar, c.owners) {
+		c.matching.Add(1)
+	}
+	return true
+}
+
+// fetchDependents crawls every dependents page of the repository at url. If
+// found is not nil, each repo is also sent on it as soon as it is parsed. If
+// onPage is not nil, it is called after each page instead of reporting
+// progress on stderr, so that code embedding the crawl can show its own.
+func fetchDependents(url, depType string, found chan<- Repo, onPage func(PageProgress)) ([]Repo, crawlStats, error) {
+	pageURL := dependentsPageURL(url, depType, packageID)
+	if startPage > 1 {
+		var err error
+		pageURL, err = skipPages(pageURL, startPage-1)
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch page %s: %v", pageURL, err)
+			return nil, crawlStats{}, err
 		}
-		defer resp.Body.Close()
+	}
+	return fetchDependentsFrom(pageURL, startPage <= 1, depType, found, onPage)
+}
 
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
+// skipPages follows the next links of n dependents pages starting at pageURL,
+// for --start-page, and returns the URL of the page after them. GitHub's
+// cursors can't be computed, so the skipped pages are fetched too.
+func skipPages(pageURL string, n int) (string, error) {
+	for page := 1; page <= n; page++ {
+		logVerbose("Skipping page %d", page)
+		doc, err := fetchPage(pageURL)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse page %s: %v", pageURL, err)
+			return "", err
 		}
+		next, hasNext := parseNext(doc)
+		if !hasNext {
+			return "", fmt.Errorf("--start-page %d is past the last of the %d dependents pages", startPage, page)
+		}
+		pageURL = next
+	}
+	return pageURL, nil
+}
 
-		pageCount++
-		pageFetched := 0
+// fetchPage fetches and parses the dependents page at pageURL.
+func fetchPage(pageURL string) (*goquery.Document, error) {
+	resp, err := getPage(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page %s: %w", pageURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, pageURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch page %s: unexpected status %s", pageURL, resp.Status)
+	}
+	doc, err := goquery.NewDocumentFromReader(limitPage(resp.Body, pageURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page %s: %w", pageURL, err)
+	}
+	return doc, nil
+}
 
-		doc.Find(itemSelector).Each(func(i int, row *goquery.Selection) {
-			repoElement := row.Find(repoSelector)
-			name := strings.TrimSpace(repoElement.Text())
-			repoURL, _ := repoElement.Attr("href")
-			fullURL := githubURL + repoURL
+// fetchDependentsFrom is fetchDependents starting at the dependents page
+// pageURL, which is the first one if fromStart is set. The completeness of
+// crawls starting later isn't known, so their GitHub total is left at 0.
+func fetchDependentsFrom(pageURL string, fromStart bool, depType string, found chan<- Repo, onPage func(PageProgress)) ([]Repo, crawlStats, error) {
+	start := time.Now()
+	usedBy := 0
+	capped := false
+	var stoppedBy truncation
 
-			starsText := strings.TrimSpace(row.Find(starsSelector).Text())
-			stars, _ := strconv.Atoi(strings.ReplaceAll(starsText, ",", ""))
+	counters := crawlCounters{seen: newDedupSet(), owners: ownersToExclude(selfRepos)}
+	// failed returns the dependents fetched before err, for --best-effort.
+	failed := func(err error) ([]Repo, crawlStats, error) {
+		stats := crawlStats{
+			Pages:     int(counters.pages.Load()),
+			UsedBy:    usedBy,
+			Duration:  time.Since(start),
+			StoppedBy: truncatedByError,
+		}
+		return counters.repos, stats, err
+	}
 
-			forksText := strings.TrimSpace(row.Find(forksSelector).Text())
-			forks, _ := strconv.Atoi(strings.ReplaceAll(forksText, ",", ""))
+	// Progress goes to onPage if set, and to the progress bar otherwise.
+	var bar *crawlProgress
+	if onPage == nil {
+		bar = newCrawlProgress()
+		defer bar.done()
+		onPage = bar.report
+	}
+	pageSize := 0
+	estimatedPages := 0
 
-			repos = append(repos, Repo{
-				Name:  name,
-				URL:   fullURL,
-				Stars: stars,
-				Forks: forks,
-			})
-			pageFetched++
+	for {
+		doc, err := fetchPage(pageURL)
+		if err != nil {
+			return failed(err)
+		}
+		if isEmptyState(doc) {
+			counters.pages.Add(1)
+			logVerbose("GitHub lists no dependents on %s", pageURL)
+			break
+		}
+		if doc.Find(dependentsSelector).Length() == 0 {
+			return failed(fmt.Errorf("%w: no %s element on %s", ErrSelectorMismatch, dependentsSelector, pageURL))
+		}
+
+		page := int(counters.pages.Add(1))
+		items := parseItems(doc, depType)
+		for _, repo := range items {
+			if maxRepos > 0 && counters.fetched.Load() >= int64(maxRepos) {
+				capped = true
+				break
+			}
+			if !counters.add(repo) {
+				logVerbose("Skipping %s, already listed on an earlier page", repo.URL)
+				continue
+			}
+			if found != nil {
+				found <- repo
+			}
+		}
+		next, hasNext := parseNext(doc)
 
-			if stars >= minStar {
-				matchingStarCriteria++
+		if page == 1 {
+			pageSize = len(items)
+			if pageSize == 0 {
+				pageSize = defaultPageSize
+			}
+			if fromStart {
+				usedBy = parseUsedBy(doc)
+				estimatedPages = (usedBy + pageSize - 1) / pageSize
+				if maxPages > 0 {
+					estimatedPages = min(estimatedPages, maxPages)
+				}
+				logVerbose("GitHub reports %d dependents, %d per page (estimated %d pages)", usedBy, pageSize, estimatedPages)
+			} else if maxPages > 0 {
+				estimatedPages = maxPages
 			}
+		} else if len(items) != pageSize && hasNext {
+			logVerbose("Page %d listed %d dependents instead of %d", page, len(items), pageSize)
+		}
+
+		onPage(PageProgress{
+			Page:           page,
+			EstimatedPages: estimatedPages,
+			Fetched:        int(counters.fetched.Load()),
+			Matching:       int(counters.matching.Load()),
+			NextURL:        next,
 		})
 
-		totalFetched += pageFetched
+		if hasNext && maxRepos > 0 && counters.fetched.Load() >= int64(maxRepos) {
+			capped = true
+		}
+		if capped {
+			stoppedBy = truncatedByMaxRepos
+			break
+		}
+		if !hasNext {
+			break
+		}
+		if maxPages > 0 && page >= maxPages {
+			stoppedBy = truncatedByMaxPages
+			break
+		}
+		pageDelay()
+		pageURL = next
+	}
 
-		// Update the tracker
-		tracker.SetValue(int64(totalFetched))
+	bar.done()
 
-		// Print current status
-		fmt.Printf("\rFetching dependents (Page: %d, Total: %d, Matching: %d)",
-			pageCount, totalFetched, matchingStarCriteria)
+	stats := crawlStats{
+		Pages:     int(counters.pages.Load()),
+		UsedBy:    usedBy,
+		Duration:  time.Since(start),
+		StoppedBy: stoppedBy,
+	}
+	fetched := int(counters.fetched.Load())
+	ratio, known := stats.completeness(fetched)
 
-		nextPage := doc.Find("#dependents > div.paginate-container > div > a:contains('Next')")
-		if nextPage.Length() == 0 {
-			break
+	if !quiet {
+		if stoppedBy == truncatedByMaxPages {
+			fmt.Fprintf(os.Stderr, "Stopped at the page limit of %d\n", maxPages)
+		}
+		fmt.Fprintf(os.Stderr, "Crawled %d pages in %s (%.1f pages/s)\n",
+			stats.Pages, stats.Duration.Round(time.Millisecond), stats.pagesPerSecond())
+		if !onlyMatching {
+			fmt.Fprintf(os.Stderr, "Total dependents fetched: %d\n", fetched)
+			if known {
+				fmt.Fprintf(os.Stderr, "Completeness: %.1f%% of the %d dependents GitHub reports\n", ratio*100, usedBy)
+			}
+		}
+		if !unfilteredCrawl {
+			fmt.Fprintf(os.Stderr, "Dependents matching the filters (--minstar %d): %d\n", minStar, counters.matching.Load())
+		}
+	}
+	if stoppedBy == truncatedByMaxRepos {
+		fmt.Fprintf(os.Stderr, "Warning: stopped the crawl after %d dependents (--max-repos)\n", maxRepos)
+	}
+	if known && ratio < completenessWarning && stoppedBy == "" {
+		fmt.Fprintf(os.Stderr, "Warning: only %d of the %d dependents GitHub reports were listed on its dependents pages\n", fetched, usedBy)
+	}
+
+	return counters.repos, stats, nil
+}
+
+// Dependent types accepted by --type.
+const (
+	typeRepository = "repository"
+	typePackage    = "package"
+)
+
+var dependentTypes = []string{typeRepository, typePackage}
+
+// resolveDependentType maps the deprecated --packages to --type package and
+// exits if --type is unknown.
+func resolveDependentType() {
+	if isPackages {
+		dependentType = typePackage
+	}
+	dependentType = strings.ToLower(dependentType)
+	if dependentType != typeRepository && dependentType != typePackage {
+		exitWithError(1, "Unknown dependent type %q (valid: %s)", dependentType, strings.Join(dependentTypes, ", "))
+	}
+}
+
+// dependentsPageURL returns the first dependents page of a repository. If
+// packageID is set, only dependents of that package of the repository are
+// listed. GitHub has no query parameters to filter dependents by visibility or
+// owner type.
+func dependentsPageURL(repoURL, depType, packageID string) string {
+	pageURL := fmt.Sprintf("%s/network/dependents?dependent_type=%s", repoURL, strings.ToUpper(depType))
+	if packageID != "" {
+		pageURL += "&package_id=" + neturl.QueryEscape(packageID)
+	}
+	return pageURL
+}
+
+// seedPageURL returns the dependents page of repoURL to start a --seed-cursor
+// crawl from. seed is either a dependents page URL, or the value of its
+// dependents_after parameter.
+func seedPageURL(repoURL, seed string) string {
+	if strings.HasPrefix(seed, "http://") || strings.HasPrefix(seed, "https://") {
+		return seed
+	}
+	return dependentsPageURL(repoURL, dependentType, packageID) + "&dependents_after=" + neturl.QueryEscape(seed)
+}
+
+// fetchDependentCount looks up how many dependents repo has itself. A
+// package dependent gets the count of its own package, picked from the
+// package menu, since the dependents page of a repository publishing several
+// packages first shows its default one. Repos without a dependents page are
+// counted as having none.
+func fetchDependentCount(repo Repo, depType string) (Repo, error) {
+	count := 0
+	repo.DependentCount = &count
+	pageURL := dependentsPageURL(repo.URL, depType, "")
+	doc, err := fetchPage(pageURL)
+	if errors.Is(err, ErrNotFound) {
+		return repo, nil
+	}
+	if err != nil {
+		return repo, err
+	}
+	if id, selected := parsePackageID(doc, repo.PackageName); id != "" && !selected {
+		pageURL = dependentsPageURL(repo.URL, depType, id)
+		if doc, err = fetchPage(pageURL); err != nil {
+			return repo, err
 		}
-		pageURL, _ = nextPage.Attr("href")
 	}
+	if count, err = usedByCount(doc, pageURL); err != nil {
+		return repo, err
+	}
+	return repo, nil
+}
 
-	// Mark the tracker as complete
-	tracker.MarkAsDone()
+// checkDependentLookups checks that sorting by dependents looks up no more
+// than --max-dependent-lookups packages, each taking one or two requests.
+func checkDependentLookups(matching int) error {
+	if maxLookups > 0 && matching > maxLookups {
+		return fmt.Errorf("sorting by dependents would look up %d matching packages, more than --max-dependent-lookups %d; raise --minstar or narrow the filters", matching, maxLookups)
+	}
+	return nil
+}
 
-	// Stop the progress writer
-	pw.Stop()
+// fetchUsedByCount returns the number of dependents GitHub reports for the
+// repository at repoURL, reading only the first dependents page.
+func fetchUsedByCount(repoURL, depType, packageID string) (int, error) {
+	pageURL := dependentsPageURL(repoURL, depType, packageID)
+	doc, err := fetchPage(pageURL)
+	if err != nil {
+		return 0, err
+	}
+	return usedByCount(doc, pageURL)
+}
 
-	fmt.Printf("\nTotal dependents fetched: %d\n", totalFetched)
-	fmt.Printf("Dependents matching minimum star criteria (%d): %d\n", minStar, matchingStarCriteria)
+// usedByCount returns the number of dependents GitHub reports on the
+// dependents page doc fetched from pageURL.
+func usedByCount(doc *goquery.Document, pageURL string) (int, error) {
+	if isEmptyState(doc) {
+		return 0, nil
+	}
+	if doc.Find(usedBySelector).Length() == 0 {
+		return 0, fmt.Errorf("%w: no %s element on %s", ErrSelectorMismatch, usedBySelector, pageURL)
+	}
+	return parseUsedBy(doc), nil
+}
 
+// parseDependentsPage returns the dependents listed on a saved dependents
+// page read from r, for --parse-only.
+func parseDependentsPage(r io.Reader, name, depType string) ([]Repo, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if isEmptyState(doc) {
+		return nil, nil
+	}
+	if doc.Find(dependentsSelector).Length() == 0 {
+		return nil, fmt.Errorf("%w: no %s element in %s", ErrSelectorMismatch, dependentsSelector, name)
+	}
+	repos := parseItems(doc, depType)
+	next, hasNext := parseNext(doc)
+	logVerbose("%s lists %d of the %d dependents GitHub reports", name, len(repos), parseUsedBy(doc))
+	if hasNext {
+		logVerbose("Next page: %s", next)
+	}
 	return repos, nil
 }
 
-func sortRepos(repos []Repo, rows, minStar int) []Repo {
-	sort.Slice(repos, func(i, j int) bool {
-		return repos[i].Stars > repos[j].Stars
+// parseItems returns the dependents listed on a dependents page, in page
+// order. Package dependents also get their package name and the ecosystem of
+// the page.
+func parseItems(doc *goquery.Document, depType string) []Repo {
+	ecosystem := ""
+	if depType == typePackage {
+		ecosystem = parseEcosystem(doc)
+	}
+
+	var repos []Repo
+	doc.Find(itemSelector).Each(func(i int, row *goquery.Selection) {
+		repoElement := row.Find(repoSelector)
+		repoURL, _ := repoElement.Attr("href")
+		stars, forks, starsMissing := parseStarsAndForks(row)
+
+		repo := Repo{
+			Name:         strings.TrimSpace(repoElement.Text()),
+			URL:          githubURL + repoURL,
+			Stars:        stars,
+			Forks:        forks,
+			StarsMissing: starsMissing,
+		}
+		if depType == typePackage {
+			repo.PackageName = strings.TrimSpace(row.Find(packageNameSelector).First().Text())
+			repo.Ecosystem = ecosystem
+		}
+		repos = append(repos, repo)
 	})
+	return repos
+}
 
-	var result []Repo
-	for _, repo := range repos {
-		if repo.Stars >= minStar {
-			result = append(result, repo)
+// parseNext returns the URL of the next dependents page, and whether there is
+// one.
+func parseNext(doc *goquery.Document) (string, bool) {
+	for _, selector := range []string{nextRelSelector, nextCursorSelector, nextSelector} {
+		if next := doc.Find(selector).First(); next.Length() > 0 {
+			return next.Attr("href")
 		}
-		if len(result) == rows {
-			break
+	}
+	return "", false
+}
+
+// parseUsedBy returns the number of dependents GitHub reports for the
+// selected dependent type, e.g. "1,234 Repositories", or 0 if it's missing.
+func parseUsedBy(doc *goquery.Document) int {
+	fields := strings.Fields(doc.Find(usedBySelector).First().Text())
+	if len(fields) == 0 {
+		return 0
+	}
+	return parseCount(fields[0])
+}
+
+// isEmptyState reports whether doc is GitHub's page for a repository or
+// package without dependents, rather than a list whose markup changed.
+func isEmptyState(doc *goquery.Document) bool {
+	return doc.Find(emptyStateSelector).Length() > 0 && doc.Find(itemSelector).Length() == 0
+}
+
+// parseStarsAndForks returns the star and fork counts of a dependent row. The
+// counts are told apart by their octicons, so they can't be swapped if GitHub
+// reorders them. Rows without the icons fall back to the star count coming
+// first.
+func parseStarsAndForks(row *goquery.Selection) (stars, forks int, starsMissing bool) {
+	starsFound, forksFound := false, false
+	row.Find(countsSelector).Each(func(i int, span *goquery.Selection) {
+		switch {
+		case span.Find(starIconSelector).Length() > 0:
+			stars, starsFound = parseCount(span.Text()), true
+		case span.Find(forkIconSelector).Length() > 0:
+			forks, forksFound = parseCount(span.Text()), true
 		}
+	})
+	// A row with a fork icon but no star icon has no star count, rather
+	// than one in the first position.
+	// The positional fallback only looks at the spans of the row's last
+	// div, as a selector like div:last-child would match the last row
+	// itself and pick up the span with its name.
+	counts := row.ChildrenFiltered("div").Last()
+	starsMissing = !starsFound && (forksFound || counts.ChildrenFiltered(starsSelector).Length() == 0)
+	if !starsFound && !starsMissing {
+		stars = parseCount(counts.ChildrenFiltered(starsSelector).Text())
+	}
+	if !forksFound {
+		forks = parseCount(counts.ChildrenFiltered(forksSelector).Text())
+	}
+	return stars, forks, starsMissing
+}
+
+// parseCount parses a count as rendered by GitHub, which depends on the
+// account's locale: "1,234" and "1.234" are both 1234, and abbreviated counts
+// such as "1.2k", "1,2k" or "12K" are expanded. Unparsable text yields 0.
+func parseCount(text string) int {
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	multiplier := 0.0
+	switch {
+	case strings.HasSuffix(text, "k"):
+		multiplier = 1e3
+	case strings.HasSuffix(text, "m"):
+		multiplier = 1e6
+	case strings.HasSuffix(text, "b"):
+		multiplier = 1e9
 	}
 
-	return result
+	if multiplier > 0 {
+		// With a suffix, either separator is the decimal point.
+		mantissa := strings.ReplaceAll(strings.TrimSpace(text[:len(text)-1]), ",", ".")
+		value, err := strconv.ParseFloat(mantissa, 64)
+		if err != nil {
+			return 0
+		}
+		return int(math.Round(value * multiplier))
+	}
+
+	// Without one, separators only group thousands.
+	text = strings.NewReplacer(",", "", ".", "", " ", "", "\u00a0", "", "\u202f", "").Replace(text)
+	count, _ := strconv.Atoi(text)
+	return count
 }
 
-func displayTable(repos []Repo) {
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"Name", "URL", "Stars", "Forks"})
-	for _, repo := range repos {
-		t.AppendRow([]interface{}{repo.Name, repo.URL, repo.Stars, repo.Forks})
+// parsePackageID returns the package_id of the package name in the package
+// menu of a dependents page, and whether the page shows that package. It
+// returns "" if the menu doesn't list name, e.g. on the page of a repository
+// publishing a single package.
+func parsePackageID(doc *goquery.Document, name string) (id string, selected bool) {
+	if name == "" {
+		return "", false
 	}
-	t.SetStyle(table.StyleLight)
-	t.Style().Options.SeparateRows = true
-	t.Render()
+	doc.Find(packageMenuItemSelector).EachWithBreak(func(i int, item *goquery.Selection) bool {
+		if !strings.EqualFold(strings.TrimSpace(item.Text()), name) {
+			return true
+		}
+		href, _ := item.Attr("href")
+		u, err := neturl.Parse(href)
+		if err != nil {
+			return true
+		}
+		id = u.Query().Get("package_id")
+		selected = item.AttrOr("aria-checked", "") == "true"
+		return false
+	})
+	return id, selected
 }
 
-func displayJSON(repos []Repo) {
-	jsonData, err := json.MarshalIndent(repos, "", "  ")
-	if err != nil {
-		fmt.Printf("Error marshalling JSON: %v\n", err)
-		return
+// parseEcosystem returns the package ecosystem shown in the package menu of a
+// package dependents page, e.g. "npm" for "Package: npm/left-pad".
+func parseEcosystem(doc *goquery.Document) string {
+	text := strings.Join(strings.Fields(doc.Find(ecosystemSelector).First().Text()), " ")
+	text = strings.TrimPrefix(text, "Package: ")
+	ecosystem, _, found := strings.Cut(text, "/")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(ecosystem)
+}
+
+func logVerbose(format string, args ...interface{}) {
+	if verbose {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
 	}
-	fmt.Println(string(jsonData))
 }
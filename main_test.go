@@ -0,0 +1,470 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const emptyStatePage = `<html><body><div id="dependents"><div class="Box"><div class="blankslate">
+<h3>We haven't found any dependents for this repository yet.</h3>
+</div></div></div></body></html>`
+
+func TestEmptyStatePageHasNoDependents(t *testing.T) {
+	if !isEmptyState(parseHTML(t, emptyStatePage)) {
+		t.Error("isEmptyState = false for the empty-state page")
+	}
+	if isEmptyState(parseHTML(t, dependentsPage(1, []string{dependentRow("o", "r", 1, 0)}, ""))) {
+		t.Error("isEmptyState = true for a page listing a dependent")
+	}
+
+	repos, err := parseDependentsPage(strings.NewReader(emptyStatePage), "empty.html", typeRepository)
+	if err != nil || len(repos) != 0 {
+		t.Errorf("parseDependentsPage = %v, %v, want no dependents and no error", repos, err)
+	}
+}
+
+func TestFetchEmptyStateReportsZero(t *testing.T) {
+	quietCrawl(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, emptyStatePage)
+	}))
+	defer srv.Close()
+
+	repos, stats, err := fetchDependents(srv.URL+"/o/r", typeRepository, nil, func(PageProgress) {})
+	if err != nil || len(repos) != 0 || stats.UsedBy != 0 {
+		t.Errorf("fetchDependents = %d repos, GitHub total %d, %v; want 0, 0, nil", len(repos), stats.UsedBy, err)
+	}
+	count, err := fetchUsedByCount(srv.URL+"/o/r", typeRepository, "")
+	if err != nil || count != 0 {
+		t.Errorf("fetchUsedByCount = %d, %v; want 0, nil", count, err)
+	}
+}
+
+// withFilters sets --minstar and --require-stars for the duration of the test.
+func withFilters(t *testing.T, min int, require bool) {
+	t.Helper()
+	savedMin, savedRequire := minStar, requireStars
+	minStar, requireStars = min, require
+	t.Cleanup(func() { minStar, requireStars = savedMin, savedRequire })
+}
+
+func TestRowWithoutStarSpanHasStarsMissing(t *testing.T) {
+	row := `<div class="Box-row" data-test-id="dg-repo-pkg-dependent">
+  <span class="f5"><a data-hovercard-type="repository" href="/o/nostars">nostars</a></span>
+  <div class="d-flex"></div>
+</div>`
+	repos := parseItems(parseHTML(t, dependentsPage(2, []string{row, dependentRow("o", "zero", 0, 0)}, "")), typeRepository)
+	if len(repos) != 2 {
+		t.Fatalf("got %d repos, want 2", len(repos))
+	}
+	if !repos[0].StarsMissing || repos[0].Stars != 0 {
+		t.Errorf("row without a star span = %+v, want stars_missing", repos[0])
+	}
+	if repos[1].StarsMissing {
+		t.Errorf("row with 0 stars = %+v, want a real 0", repos[1])
+	}
+}
+
+func TestCrawlCountersMatchRequireStars(t *testing.T) {
+	withFilters(t, 0, true)
+	c := crawlCounters{seen: newDedupSet()}
+	c.add(Repo{URL: "https://github.com/o/missing", StarsMissing: true})
+	c.add(Repo{URL: "https://github.com/o/zero"})
+	c.add(Repo{URL: "https://github.com/o/starred", Stars: 3})
+
+	kept := filterRepos(c.repos, minStar, nil)
+	if got := c.matching.Load(); got != int64(len(kept)) || got != 2 {
+		t.Errorf("matching = %d, want the %d repos kept by the filters (2)", got, len(kept))
+	}
+}
+
+func TestCrawlCountersMatchExcludedOwners(t *testing.T) {
+	withFilters(t, 0, false)
+	c := crawlCounters{seen: newDedupSet(), owners: []string{"Excluded"}}
+	c.add(Repo{URL: "https://github.com/excluded/r", Stars: 10})
+	c.add(Repo{URL: "https://github.com/kept/r", Stars: 10})
+	if got := c.matching.Load(); got != 1 {
+		t.Errorf("matching = %d, want 1", got)
+	}
+}
+
+func TestCrawlCountersConcurrentAdd(t *testing.T) {
+	withFilters(t, 5, false)
+	c := crawlCounters{seen: newDedupSet()}
+	p := &crawlProgress{}
+	savedInterval := progressInterval
+	// Status lines are only printed every that many pages.
+	progressInterval = 1 << 30
+	t.Cleanup(func() { progressInterval = savedInterval })
+
+	const workers, perWorker = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				// Every repo is added by two workers, as if listed on two
+				// pages parsed at once.
+				n := (w/2)*perWorker + i
+				c.add(Repo{URL: fmt.Sprintf("https://github.com/o/r%d", n), Stars: n % 10})
+				page := int(c.pages.Add(1))
+				p.update(PageProgress{Page: page, Fetched: int(c.fetched.Load()), Matching: int(c.matching.Load())})
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	distinct := workers / 2 * perWorker
+	if got := c.fetched.Load(); got != int64(distinct) || len(c.repos) != distinct {
+		t.Errorf("fetched = %d with %d repos, want %d", got, len(c.repos), distinct)
+	}
+	if got := c.matching.Load(); got != int64(distinct/2) {
+		t.Errorf("matching = %d, want %d", got, distinct/2)
+	}
+}
+
+func TestParseUsedBy(t *testing.T) {
+	tests := []struct {
+		html string
+		want int
+	}{
+		{dependentsPage(1234, nil, ""), 1234},
+		{`<div id="dependents"><div class="table-list-header-toggle"><a class="selected">1,234 Repositories</a></div></div>`, 1234},
+		{`<div id="dependents"><div class="table-list-header-toggle"><a class="selected">12.5k Packages</a></div></div>`, 12500},
+		{`<div id="dependents"></div>`, 0},
+	}
+	for _, tt := range tests {
+		if got := parseUsedBy(parseHTML(t, tt.html)); got != tt.want {
+			t.Errorf("parseUsedBy(%s) = %d, want %d", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestParseItems(t *testing.T) {
+	doc := parseHTML(t, dependentsPage(2, []string{
+		dependentRow("alice", "one", 37, 1),
+		dependentRow("bob", "two", 1200, 34),
+	}, ""))
+	want := []Repo{
+		{Name: "one", URL: "https://github.com/alice/one", Stars: 37, Forks: 1},
+		{Name: "two", URL: "https://github.com/bob/two", Stars: 1200, Forks: 34},
+	}
+	got := parseItems(doc, typeRepository)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("parseItems = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseItemsPackages(t *testing.T) {
+	row := `<div class="Box-row" data-test-id="dg-repo-pkg-dependent">
+  <span class="f5 color-fg-muted"><a data-hovercard-type="repository" href="/o/mono">mono</a> <span class="color-fg-muted">@o/pkg</span></span>
+  <div class="d-flex"><span><svg class="octicon octicon-star"></svg> 3</span><span><svg class="octicon octicon-repo-forked"></svg> 0</span></div>
+</div>`
+	page := strings.Replace(dependentsPage(1, []string{row}, ""), `<div class="Box">`,
+		`<div class="select-menu-button">Package: npm/left-pad</div><div class="Box">`, 1)
+	got := parseItems(parseHTML(t, page), typePackage)
+	if len(got) != 1 || got[0].PackageName != "@o/pkg" || got[0].Ecosystem != "npm" {
+		t.Errorf("parseItems = %+v, want package @o/pkg in npm", got)
+	}
+}
+
+func TestParseNext(t *testing.T) {
+	const cursor = "https://github.com/o/r/network/dependents?dependents_after=abc"
+	tests := []struct {
+		name     string
+		links    string
+		want     string
+		wantNext bool
+	}{
+		{"Next text", `<a class="btn" href="` + cursor + `">Next</a>`, cursor, true},
+		{"rel next", `<a rel="nofollow next" href="` + cursor + `">›</a>`, cursor, true},
+		{"cursor link", `<a class="btn" disabled>Previous</a><a href="` + cursor + `">Weiter</a>`, cursor, true},
+		{"last page", `<a class="btn" href="https://github.com/o/r/network/dependents?dependents_before=abc">Previous</a><button disabled>Next</button>`, "", false},
+		{"single page", ``, "", false},
+	}
+	for _, tt := range tests {
+		html := `<div id="dependents"><div class="paginate-container"><div class="BtnGroup">` + tt.links + `</div></div></div>`
+		got, ok := parseNext(parseHTML(t, html))
+		if got != tt.want || ok != tt.wantNext {
+			t.Errorf("%s: parseNext = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantNext)
+		}
+	}
+}
+
+// countsRow renders a dependent row with the given count spans.
+func countsRow(spans string) string {
+	return `<div class="Box-row" data-test-id="dg-repo-pkg-dependent">
+  <span class="f5"><a data-hovercard-type="repository" href="/o/r">r</a></span>
+  <div class="d-flex">` + spans + `</div>
+</div>`
+}
+
+func TestParseStarsAndForks(t *testing.T) {
+	const (
+		star = `<span><svg aria-label="star" class="octicon octicon-star"></svg> %s</span>`
+		fork = `<span><svg aria-label="fork" class="octicon octicon-repo-forked"></svg> %s</span>`
+	)
+	tests := []struct {
+		name        string
+		spans       string
+		stars       int
+		forks       int
+		missingStar bool
+	}{
+		{"stars then forks", fmt.Sprintf(star, "120") + fmt.Sprintf(fork, "7"), 120, 7, false},
+		{"forks then stars", fmt.Sprintf(fork, "7") + fmt.Sprintf(star, "120"), 120, 7, false},
+		{"only the fork icon", fmt.Sprintf(fork, "7"), 0, 7, true},
+		{"no icons", `<span>120</span><span>7</span>`, 120, 7, false},
+		{"no counts", ``, 0, 0, true},
+	}
+	for _, tt := range tests {
+		doc := parseHTML(t, dependentsPage(1, []string{countsRow(tt.spans)}, ""))
+		stars, forks, missing := parseStarsAndForks(doc.Find(itemSelector).First())
+		if stars != tt.stars || forks != tt.forks || missing != tt.missingStar {
+			t.Errorf("%s: parseStarsAndForks = %d, %d, %v; want %d, %d, %v",
+				tt.name, stars, forks, missing, tt.stars, tt.forks, tt.missingStar)
+		}
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"1.234", 1234},
+		{"1,234", 1234},
+		{"1.2k", 1200},
+		{"1,2k", 1200},
+		{"12K", 12000},
+		{" 3.4M ", 3400000},
+		{"1 234", 1234},
+		{"42", 42},
+		{"many", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parseCount(tt.text); got != tt.want {
+			t.Errorf("parseCount(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+// packageMenu renders the package menu of a dependents page listing the
+// packages of /o/mono, with selected checked.
+func packageMenu(selected string) string {
+	var b strings.Builder
+	for _, pkg := range []struct{ id, name string }{{"A", "@o/small"}, {"B", "@o/big"}} {
+		fmt.Fprintf(&b, `<a class="select-menu-item" href="/o/mono/network/dependents?dependent_type=PACKAGE&package_id=%s" aria-checked="%t"><span class="select-menu-item-text">%s</span></a>`,
+			pkg.id, pkg.id == selected, pkg.name)
+	}
+	return `<div class="select-menu-list">` + b.String() + `</div>`
+}
+
+func TestSortByDependentsUsesEachPackagesCount(t *testing.T) {
+	quietCrawl(t)
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var page string
+		switch r.URL.Path {
+		case "/o/mono/network/dependents":
+			// @o/small is the default package of /o/mono.
+			if r.URL.Query().Get("package_id") == "B" {
+				page = dependentsPage(50, nil, "")
+				page = strings.Replace(page, `<div class="Box">`, packageMenu("B")+`<div class="Box">`, 1)
+			} else {
+				page = dependentsPage(5, nil, "")
+				page = strings.Replace(page, `<div class="Box">`, packageMenu("A")+`<div class="Box">`, 1)
+			}
+		case "/o/solo/network/dependents":
+			page = dependentsPage(20, nil, "")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	defer srv.Close()
+
+	matching := []Repo{
+		{Name: "mono", URL: srv.URL + "/o/mono", Stars: 100, PackageName: "@o/small"},
+		{Name: "mono", URL: srv.URL + "/o/mono", Stars: 10, PackageName: "@o/big"},
+		{Name: "solo", URL: srv.URL + "/o/solo", Stars: 50, PackageName: "solo"},
+		{Name: "gone", URL: srv.URL + "/o/gone", Stars: 1, PackageName: "gone"},
+	}
+	enriched, err := enrichRepos(matching, 1, func(repo Repo) (Repo, error) {
+		return fetchDependentCount(repo, typePackage)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, repo := range Results(enriched).SortBy("dependents") {
+		got = append(got, fmt.Sprintf("%s %d", repo.PackageName, dependentCount(repo)))
+	}
+	want := []string{"@o/big 50", "solo 20", "@o/small 5", "gone 0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted by dependents = %v, want %v", got, want)
+	}
+	// Only @o/big isn't the default package, so it takes a second request.
+	if requests.Load() != 5 {
+		t.Errorf("made %d requests, want 5", requests.Load())
+	}
+}
+
+func TestCheckDependentLookups(t *testing.T) {
+	saved := maxLookups
+	t.Cleanup(func() { maxLookups = saved })
+
+	tests := []struct {
+		max, matching int
+		ok            bool
+	}{
+		{500, 500, true},
+		{500, 501, false},
+		{0, 100000, true},
+	}
+	for _, tt := range tests {
+		maxLookups = tt.max
+		if err := checkDependentLookups(tt.matching); (err == nil) != tt.ok {
+			t.Errorf("checkDependentLookups(%d) with --max-dependent-lookups %d = %v", tt.matching, tt.max, err)
+		}
+	}
+}
+
+func TestCheckStreamLookups(t *testing.T) {
+	savedStream, savedDepth, savedSort, savedThenBy, savedRedirects := stream, depth, sortKey, thenBy, resolveRedirects
+	t.Cleanup(func() {
+		stream, depth, sortKey, thenBy, resolveRedirects = savedStream, savedDepth, savedSort, savedThenBy, savedRedirects
+	})
+
+	tests := []struct {
+		name      string
+		set       func()
+		wantError string
+	}{
+		{"depth 2", func() { depth = 2 }, "--depth 2 can't be used with --stream"},
+		{"sort dependents", func() { sortKey = "dependents" }, "--sort dependents and --then-by dependents need all dependents and can't be used with --stream"},
+		{"then-by dependents", func() { thenBy = "dependents" }, "--sort dependents and --then-by dependents need all dependents and can't be used with --stream"},
+		{"resolve redirects", func() { resolveRedirects = true }, "--resolve-redirects can't be used with --stream"},
+	}
+	for _, tt := range tests {
+		stream, depth, sortKey, thenBy, resolveRedirects = true, 1, "stars", "", false
+		tt.set()
+		err := checkStreamLookups()
+		if err == nil || err.Error() != tt.wantError {
+			t.Errorf("%s with --stream: err = %v, want %q", tt.name, err, tt.wantError)
+		}
+		// Without --stream they are fine.
+		stream = false
+		if err := checkStreamLookups(); err != nil {
+			t.Errorf("%s without --stream: %v", tt.name, err)
+		}
+	}
+}
+
+func TestRankRepos(t *testing.T) {
+	savedSample, savedWeighted, savedSort, savedThenBy, savedPerOwner, savedRows := sampleSize, sampleWeighted, sortKey, thenBy, maxPerOwner, rows
+	t.Cleanup(func() {
+		sampleSize, sampleWeighted, sortKey, thenBy, maxPerOwner, rows = savedSample, savedWeighted, savedSort, savedThenBy, savedPerOwner, savedRows
+	})
+
+	matching := []Repo{
+		testRepo("a", "one", 10, 3),
+		testRepo("b", "two", 30, 1),
+		testRepo("a", "three", 20, 3),
+		testRepo("c", "four", 5, 9),
+	}
+	tests := []struct {
+		name string
+		set  func()
+		want []string
+	}{
+		{"sorted by stars", func() {}, []string{"b/two", "a/three", "a/one", "c/four"}},
+		{"cut to --rows", func() { rows = 2 }, []string{"b/two", "a/three"}},
+		{"then by stars", func() { sortKey, thenBy = "forks", "stars" }, []string{"c/four", "a/three", "a/one", "b/two"}},
+		{"max per owner", func() { maxPerOwner = 1 }, []string{"b/two", "a/three", "c/four"}},
+		{"max per owner before --rows", func() { maxPerOwner, rows = 1, 3 }, []string{"b/two", "a/three", "c/four"}},
+		{"sample of all", func() { sampleSize = 10 }, []string{"b/two", "a/three", "a/one", "c/four"}},
+	}
+	for _, tt := range tests {
+		sampleSize, sampleWeighted, sortKey, thenBy, maxPerOwner, rows = 0, false, "stars", "", 0, 10
+		tt.set()
+		got := fullNames(rankRepos(matching, rand.New(rand.NewSource(1))))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// A random order or a sample draws from rng.
+	for _, tt := range []struct {
+		set  func()
+		want int
+	}{
+		{func() { sortKey = "random" }, 4},
+		{func() { sampleSize = 2 }, 2},
+		{func() { sampleSize, sampleWeighted = 2, true }, 2},
+	} {
+		sampleSize, sampleWeighted, sortKey, thenBy, maxPerOwner, rows = 0, false, "stars", "", 0, 10
+		tt.set()
+		a := rankRepos(matching, rand.New(rand.NewSource(7)))
+		b := rankRepos(matching, rand.New(rand.NewSource(7)))
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("sort %q, sample %d: same seed gave %v and %v", sortKey, sampleSize, fullNames(a), fullNames(b))
+		}
+		if len(a) != tt.want {
+			t.Errorf("sort %q, sample %d: got %d dependents, want %d", sortKey, sampleSize, len(a), tt.want)
+		}
+	}
+}
+
+func TestUnfilteredCrawlSummaryHasNoMatchingCount(t *testing.T) {
+	quietCrawl(t)
+	_, repoURL := newDependentsServer(t, 1, 2)
+	saved := unfilteredCrawl
+	t.Cleanup(func() { unfilteredCrawl = saved })
+
+	for _, unfiltered := range []bool{false, true} {
+		quiet, unfilteredCrawl = false, unfiltered
+		stderr := captureStderr(t, func() {
+			if _, _, err := fetchDependents(repoURL, typeRepository, nil, func(PageProgress) {}); err != nil {
+				t.Error(err)
+			}
+		})
+		if !strings.Contains(stderr, "Total dependents fetched: 2") {
+			t.Errorf("unfiltered %v: summary lacks the fetched count:\n%s", unfiltered, stderr)
+		}
+		if got := strings.Contains(stderr, "matching the filters"); got == unfiltered {
+			t.Errorf("unfiltered %v: summary has a matching count: %v\n%s", unfiltered, got, stderr)
+		}
+	}
+}
+
+func TestLogVerbosePrintsOneLine(t *testing.T) {
+	saved := verbose
+	t.Cleanup(func() { verbose = saved })
+
+	for _, v := range []bool{false, true} {
+		verbose = v
+		stderr := captureStderr(t, func() {
+			logVerbose("Fetching %s", "page 1")
+			logVerbose("Fetching %s", "page 2")
+		})
+		want := ""
+		if v {
+			want = "Fetching page 1\nFetching page 2\n"
+		}
+		if stderr != want {
+			t.Errorf("verbose %v: logged %q, want %q", v, stderr, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+// crawlMeta is the metadata included in JSON output with --include-meta.
+type crawlMeta struct {
+	Pages           int     `json:"pages"`
+	Requests        int64   `json:"requests"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	PagesPerSecond  float64 `json:"pages_per_second"`
+	GitHubTotal     int     `json:"github_total"`
+	Fetched         int     `json:"fetched"`
+	// Completeness is Fetched divided by GitHubTotal, omitted when GitHub
+	// reports no total.
+	Completeness   *float64 `json:"completeness,omitempty"`
+	Matching       int      `json:"matching"`
+	Shown          int      `json:"shown"`
+	DistinctOwners int      `json:"distinct_owners"`
+	// Truncated is set when the output doesn't include every dependent
+	// matching the filters, and TruncatedBy tells why.
+	Truncated   bool       `json:"truncated"`
+	TruncatedBy truncation `json:"truncated_by,omitempty"`
+	// Histogram is only included with --histogram.
+	Histogram []histogramBucket `json:"histogram,omitempty"`
+}
+
+// newCrawlMeta describes a crawl that fetched repos, of which matching passed
+// the filters and shown are included in the output.
+func newCrawlMeta(stats crawlStats, repos, matching, shown []Repo) crawlMeta {
+	meta := crawlMeta{
+		Pages:           stats.Pages,
+		Requests:        requestCount.Load(),
+		DurationSeconds: stats.Duration.Seconds(),
+		PagesPerSecond:  stats.pagesPerSecond(),
+		GitHubTotal:     stats.UsedBy,
+		Fetched:         len(repos),
+		Matching:        len(matching),
+		Shown:           len(shown),
+		DistinctOwners:  countOwners(matching),
+	}
+	meta.TruncatedBy = truncationCause(stats, matching, shown)
+	meta.Truncated = meta.TruncatedBy != ""
+	if histogram {
+		meta.Histogram = starHistogram(repos)
+	}
+	if ratio, known := stats.completeness(len(repos)); known {
+		meta.Completeness = &ratio
+	}
+	return meta
+}
+
+// queryMeta is the query that produced the output, included in JSON output
+// with --include-meta so saved results show how they were made.
+type queryMeta struct {
+	URLs           []string `json:"urls,omitempty"`
+	FromFile       string   `json:"from_file,omitempty"`
+	DependentType  string   `json:"dependent_type"`
+	PackageID      string   `json:"package_id,omitempty"`
+	MinStars       int      `json:"minstar"`
+	MaxStars       int      `json:"max_stars,omitempty"`
+	Sort           string   `json:"sort"`
+	ThenBy         string   `json:"then_by,omitempty"`
+	Rows           int      `json:"rows"`
+	Sample         int      `json:"sample,omitempty"`
+	Topics         []string `json:"topics,omitempty"`
+	OnlyForks      bool     `json:"only_forks,omitempty"`
+	ExcludeForks   bool     `json:"exclude_forks,omitempty"`
+	CreatedBefore  string   `json:"created_before,omitempty"`
+	CreatedAfter   string   `json:"created_after,omitempty"`
+	ExcludedOwners []string `json:"excluded_owners,omitempty"`
+	IncludeSelf    bool     `json:"include_self,omitempty"`
+}
+
+// newQueryMeta describes the query of this run, which excluded the
+// dependents of owners.
+func newQueryMeta(owners []string) queryMeta {
+	return queryMeta{
+		URLs:           selfRepos,
+		FromFile:       fromFile,
+		DependentType:  dependentType,
+		PackageID:      packageID,
+		MinStars:       minStar,
+		MaxStars:       maxStars,
+		Sort:           sortKey,
+		ThenBy:         thenBy,
+		Rows:           rows,
+		Sample:         sampleSize,
+		Topics:         topics,
+		OnlyForks:      onlyForks,
+		ExcludeForks:   excludeForks,
+		CreatedBefore:  createdBefore,
+		CreatedAfter:   createdAfter,
+		ExcludedOwners: owners,
+		IncludeSelf:    includeSelf,
+	}
+}
+
+// truncationCause returns why the output omits dependents: the crawl
+// stopping early takes precedence over --rows, since the matching count is
+// incomplete then as well.
+func truncationCause(stats crawlStats, matching, shown []Repo) truncation {
+	if stats.StoppedBy != "" {
+		return stats.StoppedBy
+	}
+	if len(matching) > len(shown) {
+		if sampleSize > 0 {
+			return truncatedBySample
+		}
+		if maxPerOwner > 0 && (rows == 0 || len(shown) < rows) {
+			return truncatedByMaxPerOwner
+		}
+		return truncatedByRows
+	}
+	return ""
+}
@@ -0,0 +1,628 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/xuri/excelize/v2"
+)
+
+// output is one of the formats given with --format and where it is written:
+// to file, or to stdout if file is empty.
+type output struct {
+	format string
+	file   string
+	w      io.Writer
+}
+
+// planOutputs pairs formats with the --output-file files in order. With one
+// file fewer than formats, the first format is written to stdout and the
+// others to the files, so "--format table,json -o out.json" shows the table
+// and saves the JSON. A file named "-" is stdout too, for any other pairing.
+func planOutputs(formats, files []string) ([]output, error) {
+	switch {
+	case len(files) == len(formats)-1:
+		files = append([]string{"-"}, files...)
+	case len(files) != len(formats):
+		return nil, fmt.Errorf("got %d output files for %d formats, expected %d or %d", len(files), len(formats), len(formats)-1, len(formats))
+	}
+
+	outputs := make([]output, len(formats))
+	toStdout := 0
+	for i, f := range formats {
+		if !isOutputFormat(f) {
+			return nil, fmt.Errorf("Unknown format %q (valid: %s)", f, strings.Join(outputFormats, ", "))
+		}
+		outputs[i] = output{format: f}
+		if files[i] != "-" {
+			outputs[i].file = files[i]
+			continue
+		}
+		if f == "xlsx" || f == "sqlite" {
+			return nil, fmt.Errorf("--format %s requires --output-file", f)
+		}
+		toStdout++
+	}
+	if toStdout > 1 {
+		return nil, fmt.Errorf("only one format can be written to stdout, give the others an --output-file")
+	}
+	return outputs, nil
+}
+
+// hasFormat reports whether any of outputs is in format f.
+func hasFormat(outputs []output, f string) bool {
+	for _, o := range outputs {
+		if o.format == f {
+			return true
+		}
+	}
+	return false
+}
+
+func displayTable(w io.Writer, repos []Repo) {
+	columns := activeColumns()
+
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	header := table.Row{}
+	var configs []table.ColumnConfig
+	for i, c := range columns {
+		header = append(header, columnHeader(c.key))
+		if c.key == "name" && nameWidth > 0 {
+			configs = append(configs, table.ColumnConfig{Number: i + 1, WidthMax: nameWidth, WidthMaxEnforcer: truncateWithEllipsis})
+		}
+		switch {
+		case c.float:
+			configs = append(configs, table.ColumnConfig{Number: i + 1, Transformer: precisionTransformer})
+		case humanNumbers && c.numeric:
+			configs = append(configs, table.ColumnConfig{Number: i + 1, Transformer: humanNumberTransformer})
+		}
+	}
+	t.AppendHeader(header)
+	for _, repo := range repos {
+		t.AppendRow(columnValues(columns, repo))
+	}
+	t.SetColumnConfigs(configs)
+	t.SetStyle(tableStyle(tableStyleName))
+	t.Render()
+}
+
+// tableStyleNames lists the values accepted by --table-style.
+var tableStyleNames = []string{"light", "bold", "double", "rounded", "ascii", "compact", "none"}
+
+// tableStyle returns the go-pretty style for a --table-style name. The
+// bordered styles separate rows, compact is light without them, and none
+// draws no lines at all.
+func tableStyle(name string) table.Style {
+	var style table.Style
+	switch name {
+	case "bold":
+		style = table.StyleBold
+	case "double":
+		style = table.StyleDouble
+	case "rounded":
+		style = table.StyleRounded
+	case "ascii":
+		style = table.StyleDefault
+	case "compact":
+		return table.StyleLight
+	case "none":
+		style = table.StyleDefault
+		style.Options = table.OptionsNoBordersAndSeparators
+		return style
+	default:
+		style = table.StyleLight
+	}
+	style.Options.SeparateRows = true
+	return style
+}
+
+func isTableStyle(name string) bool {
+	for _, s := range tableStyleNames {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func displayJSON(w io.Writer, repos []Repo) error {
+	jsonData, err := json.MarshalIndent(jsonRepos(repos), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+// displayJSONWithMeta writes the repos together with the query and crawl
+// metadata as a single JSON object.
+func displayJSONWithMeta(w io.Writer, repos []Repo, query queryMeta, meta crawlMeta) error {
+	result := struct {
+		Query queryMeta   `json:"query"`
+		Meta  crawlMeta   `json:"meta"`
+		Repos interface{} `json:"repos"`
+	}{query, meta, jsonRepos(repos)}
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+// displayLines writes one line per repo in the ndjson or urls format.
+func displayLines(w io.Writer, repos []Repo) error {
+	for _, repo := range repos {
+		if err := writeLine(w, repo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// displayTop writes the owner/name of the first repo, the top one in the
+// sort order, for --format top. Nothing is written if there are no repos.
+func displayTop(w io.Writer, repos []Repo) error {
+	if len(repos) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintln(w, repoFullName(repos[0].URL))
+	return err
+}
+
+// displayCSV writes the repos as CSV with the table columns.
+func displayCSV(w io.Writer, repos []Repo) error {
+	columns := activeColumns()
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader(columns)); err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		if err := cw.Write(csvRecord(columns, repo)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// streamRepos writes every repo received on found that passes keep, as soon
+// as it arrives, stopping after limit repos (0 for no limit). It keeps
+// draining found afterwards so the crawl is never blocked.
+func streamRepos(w io.Writer, found <-chan Repo, limit int, keep func(Repo) bool) error {
+	write := func(repo Repo) error { return writeLine(w, repo) }
+	var err error
+	if format == "csv" {
+		// Each row is flushed right away so it reaches the reader while
+		// the crawl goes on.
+		columns := activeColumns()
+		cw := csv.NewWriter(w)
+		write = func(repo Repo) error {
+			cw.Write(csvRecord(columns, repo))
+			cw.Flush()
+			return cw.Error()
+		}
+		cw.Write(csvHeader(columns))
+		cw.Flush()
+		err = cw.Error()
+	}
+
+	written := 0
+	for repo := range found {
+		if err != nil || !keep(repo) || (limit > 0 && written == limit) {
+			continue
+		}
+		err = write(repo)
+		written++
+	}
+	return err
+}
+
+// writeLine writes repo as a single line: a JSON object for ndjson, or just
+// the URL for urls.
+func writeLine(w io.Writer, repo Repo) error {
+	if format == "urls" {
+		_, err := fmt.Fprintln(w, repo.URL)
+		return err
+	}
+	jsonData, err := json.Marshal(jsonRepo(repo))
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+// displayBadge prints a shields.io endpoint badge with the number of matching
+// dependents, see https://shields.io/badges/endpoint-badge.
+func displayBadge(w io.Writer, count int) error {
+	badge := struct {
+		SchemaVersion int    `json:"schemaVersion"`
+		Label         string `json:"label"`
+		Message       string `json:"message"`
+	}{
+		SchemaVersion: 1,
+		Label:         "dependents",
+		Message:       strconv.Itoa(count),
+	}
+	jsonData, err := json.Marshal(badge)
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+// displayMarkdown writes the table as GitHub-flavored markdown, with names
+// linking to the repos. With --collapsible it is wrapped in a <details> block
+// titled --summary-text, so it doesn't take up a README until expanded.
+func displayMarkdown(w io.Writer, repos []Repo) error {
+	columns := activeColumns()
+
+	t := table.NewWriter()
+	header := table.Row{}
+	var configs []table.ColumnConfig
+	for i, c := range columns {
+		header = append(header, columnHeader(c.key))
+		switch {
+		case c.float:
+			configs = append(configs, table.ColumnConfig{Number: i + 1, Transformer: precisionTransformer})
+		case humanNumbers && c.numeric:
+			configs = append(configs, table.ColumnConfig{Number: i + 1, Transformer: humanNumberTransformer})
+		}
+	}
+	t.AppendHeader(header)
+	for _, repo := range repos {
+		values := columnValues(columns, repo)
+		for i, c := range columns {
+			if c.key == "name" {
+				values[i] = fmt.Sprintf("[%s](%s)", values[i], repo.URL)
+			}
+		}
+		t.AppendRow(values)
+	}
+	t.SetColumnConfigs(configs)
+
+	var err error
+	if collapsible {
+		_, err = fmt.Fprintf(w, "<details>\n<summary>%s</summary>\n\n%s\n\n</details>\n", html.EscapeString(summaryText), t.RenderMarkdown())
+	} else {
+		_, err = fmt.Fprintln(w, t.RenderMarkdown())
+	}
+	return err
+}
+
+// displayBadges writes a markdown shields.io badge per repo with its name and
+// star count, linking to the repo, e.g. for a "Notable users" README section.
+func displayBadges(w io.Writer, repos []Repo) error {
+	for _, repo := range repos {
+		name := displayName(repo)
+		stars := formatHumanNumber(repo.Stars) + " stars"
+		badgeURL := "https://img.shields.io/badge/" + shieldsEscape(name) + "-" + shieldsEscape(stars) + "-blue?logo=github"
+		if _, err := fmt.Fprintf(w, "[![%s](%s)](%s)\n", name, badgeURL, repo.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shieldsEscape escapes s for a static shields.io badge URL, where dashes and
+// underscores separate the label from the message.
+func shieldsEscape(s string) string {
+	s = strings.ReplaceAll(s, "-", "--")
+	s = strings.ReplaceAll(s, "_", "__")
+	return strings.ReplaceAll(url.PathEscape(s), "%20", "_")
+}
+
+// displayXLSX writes the repos as an Excel workbook with a frozen header row
+// and numeric cells for counts, so they sort and filter as numbers.
+func displayXLSX(w io.Writer, repos []Repo) error {
+	const sheet = "Dependents"
+
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return err
+	}
+
+	columns := activeColumns()
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = columnHeader(c.key)
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	for i, repo := range repos {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		row := columnValues(columns, repo)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	if err := f.SetPanes(sheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+	return f.Write(w)
+}
+
+func csvHeader(columns []column) []string {
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = columnHeader(c.key)
+	}
+	return header
+}
+
+// csvRecord returns the column values of repo as CSV fields. Derived metrics
+// keep their full precision, as in JSON.
+func csvRecord(columns []column, repo Repo) []string {
+	record := make([]string, len(columns))
+	for i, value := range columnValues(columns, repo) {
+		if f, ok := value.(float64); ok {
+			record[i] = strconv.FormatFloat(f, 'f', -1, 64)
+		} else {
+			record[i] = fmt.Sprint(value)
+		}
+	}
+	return record
+}
+
+// repoWithEmpty is Repo without omitempty, so that --include-empty writes
+// optional fields that weren't fetched too. Its fields must match Repo's.
+type repoWithEmpty struct {
+	Name            string     `json:"name"`
+	URL             string     `json:"url"`
+	Stars           int        `json:"stars"`
+	Forks           int        `json:"forks"`
+	StarsMissing    bool       `json:"stars_missing"`
+	DependentCount  *int       `json:"dependent_count"`
+	PackageName     string     `json:"package_name"`
+	Ecosystem       string     `json:"ecosystem"`
+	NormalizedStars *float64   `json:"normalized_stars"`
+	Percentile      *float64   `json:"percentile"`
+	Topics          []string   `json:"topics"`
+	Fork            *bool      `json:"fork"`
+	CreatedAt       *time.Time `json:"created_at"`
+}
+
+// jsonRepo returns repo as it is marshalled to JSON, honouring
+// --include-empty. Field order follows the struct, so output is stable.
+func jsonRepo(repo Repo) interface{} {
+	var value interface{} = repo
+	if includeEmpty {
+		value = repoWithEmpty(repo)
+	}
+	if len(columnsOrder) > 0 {
+		return orderedJSON{value}
+	}
+	return value
+}
+
+func jsonRepos(repos []Repo) interface{} {
+	if repos == nil {
+		// No dependents are written as [] rather than null.
+		repos = []Repo{}
+	}
+	if jsonKeyed {
+		return keyedRepos(repos)
+	}
+	if !includeEmpty && len(columnsOrder) == 0 {
+		return repos
+	}
+	result := make([]interface{}, len(repos))
+	for i, repo := range repos {
+		result[i] = jsonRepo(repo)
+	}
+	return result
+}
+
+// orderedJSON marshals a JSON object with the fields of the columns named by
+// --columns-order first, in that order, and the others after them.
+type orderedJSON struct {
+	value interface{}
+}
+
+func (o orderedJSON) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(o.value)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	values := make(map[string]json.RawMessage)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, key.(string))
+		values[key.(string)] = value
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	write := func(field string) {
+		value, ok := values[field]
+		if !ok {
+			return
+		}
+		delete(values, field)
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	for _, field := range jsonFieldOrder() {
+		write(field)
+	}
+	for _, field := range fields {
+		write(field)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// keyedRepos marshals to a JSON object keyed by repo URL, for --json-keyed.
+// Keys are written in ranking order and the values leave out the URL.
+type keyedRepos []Repo
+
+func (repos keyedRepos) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, repo := range repos {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(repo.URL)
+		if err != nil {
+			return nil, err
+		}
+		// The outer, empty URL field hides the embedded one.
+		var value interface{} = struct {
+			Repo
+			URL string `json:"url,omitempty"`
+		}{Repo: repo}
+		if includeEmpty {
+			value = struct {
+				repoWithEmpty
+				URL string `json:"url,omitempty"`
+			}{repoWithEmpty: repoWithEmpty(repo)}
+		}
+		if len(columnsOrder) > 0 {
+			value = orderedJSON{value}
+		}
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(valueJSON)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func isOutputFormat(name string) bool {
+	for _, f := range outputFormats {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateWithEllipsis shortens names wider than maxLen to fit, ending them
+// with an ellipsis, for --name-width. Widths are in terminal columns, so a
+// wide rune that would cross maxLen is cut off whole rather than split
+// (text.Snip counts runes, and can return a name wider than it was).
+func truncateWithEllipsis(s string, maxLen int) string {
+	if text.RuneWidthWithoutEscSequences(s) <= maxLen {
+		return s
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		if width+text.RuneWidth(r) > maxLen-1 {
+			break
+		}
+		b.WriteRune(r)
+		width += text.RuneWidth(r)
+	}
+	return b.String() + "…"
+}
+
+func humanNumberTransformer(val interface{}) string {
+	if n, ok := val.(int); ok {
+		return formatHumanNumber(n)
+	}
+	return fmt.Sprint(val)
+}
+
+// precisionTransformer renders derived float columns with --precision
+// decimal places.
+func precisionTransformer(val interface{}) string {
+	if f, ok := val.(float64); ok {
+		return strconv.FormatFloat(f, 'f', precision, 64)
+	}
+	return fmt.Sprint(val)
+}
+
+// formatHumanNumber abbreviates large counts the way GitHub does, so 12345
+// becomes 12.3k and 1500000 becomes 1.5m. The number of decimals is set by
+// --precision, dropping trailing zeros.
+func formatHumanNumber(n int) string {
+	units := []struct {
+		size   float64
+		suffix string
+	}{
+		{1e9, "b"},
+		{1e6, "m"},
+		{1e3, "k"},
+	}
+	for i, unit := range units {
+		if float64(n) < unit.size {
+			continue
+		}
+		s := strconv.FormatFloat(float64(n)/unit.size, 'f', precision, 64)
+		if rounded, _ := strconv.ParseFloat(s, 64); rounded >= 1000 && i > 0 {
+			// Rounding reached the next unit, e.g. 999,950 is 1m, not 1000k.
+			unit = units[i-1]
+			s = strconv.FormatFloat(float64(n)/unit.size, 'f', precision, 64)
+		}
+		if strings.Contains(s, ".") {
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		}
+		return s + unit.suffix
+	}
+	return strconv.Itoa(n)
+}
+
+// ansiEscape matches ANSI CSI escape sequences, such as colors and cursor
+// movements.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// ansiStripper removes ANSI escape sequences from what is written to w, for
+// --strip-ansi. Sequences split across writes are not detected.
+type ansiStripper struct {
+	w io.Writer
+}
+
+func (s ansiStripper) Write(p []byte) (int, error) {
+	if _, err := s.w.Write(ansiEscape.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
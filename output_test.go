@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+func TestDisplayJSONKeepsZeroDependentCount(t *testing.T) {
+	zero := 0
+	repos := []Repo{
+		{Name: "fetched", URL: "https://github.com/o/fetched", DependentCount: &zero},
+		{Name: "unfetched", URL: "https://github.com/o/unfetched"},
+	}
+	var out bytes.Buffer
+	if err := displayJSON(&out, repos); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(out.String(), `"dependent_count": 0`); got != 1 {
+		t.Errorf("got %d zero dependent counts, want 1 for the fetched repo:\n%s", got, out.String())
+	}
+}
+
+func TestDisplayJSONWithoutReposWritesEmptyArray(t *testing.T) {
+	var out bytes.Buffer
+	if err := displayJSON(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "[]" {
+		t.Errorf("displayJSON(nil) = %s, want []", got)
+	}
+}
+
+func TestStripANSIRemovesEscapesFromTable(t *testing.T) {
+	// Short names show the names as scraped, escapes and all.
+	saved := shortNames
+	shortNames = true
+	t.Cleanup(func() { shortNames = saved })
+
+	repos := []Repo{
+		{Name: "\x1b[31mevil\x1b[0m", URL: "https://github.com/o/evil", Stars: 10},
+		{Name: "clear\x1b[2J\x1b[H", URL: "https://github.com/o/clear", Stars: 5},
+	}
+	var out bytes.Buffer
+	displayTable(ansiStripper{&out}, repos)
+	if strings.Contains(out.String(), "\x1b") {
+		t.Errorf("table has escape sequences with --strip-ansi:\n%q", out.String())
+	}
+	for _, name := range []string{"evil", "clear"} {
+		if !strings.Contains(out.String(), name) {
+			t.Errorf("table lost the name %s:\n%s", name, out.String())
+		}
+	}
+}
+
+func TestANSIStripperReportsBytesWritten(t *testing.T) {
+	var out bytes.Buffer
+	p := []byte("\x1b[1;32mok\x1b[0m\n")
+	n, err := ansiStripper{&out}.Write(p)
+	if err != nil || n != len(p) {
+		t.Errorf("Write = %d, %v; want %d, nil", n, err, len(p))
+	}
+	if out.String() != "ok\n" {
+		t.Errorf("wrote %q, want %q", out.String(), "ok\n")
+	}
+}
+
+func TestTruncateWithEllipsisMultiByte(t *testing.T) {
+	tests := []struct {
+		name   string
+		maxLen int
+		want   string
+	}{
+		{"héllo-wörld", 5, "héll…"},
+		{"naïve", 3, "na…"},
+		{"naïve", 4, "naï…"},
+		{"naïve", 5, "naïve"},
+		// Wide runes take two columns and aren't split at the cut-off.
+		{"日本語テキスト", 5, "日本…"},
+		{"日本語テキスト", 6, "日本…"},
+		{"ab日本", 5, "ab日…"},
+		{"ab日本", 4, "ab…"},
+		{"ab日本", 6, "ab日本"},
+		{"a😀b😀c", 4, "a😀…"},
+		{"lib", 1, "…"},
+	}
+	for _, tt := range tests {
+		got := truncateWithEllipsis(tt.name, tt.maxLen)
+		if got != tt.want {
+			t.Errorf("truncateWithEllipsis(%q, %d) = %q, want %q", tt.name, tt.maxLen, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncateWithEllipsis(%q, %d) split a rune: %q", tt.name, tt.maxLen, got)
+		}
+		if w := text.RuneWidthWithoutEscSequences(got); w > tt.maxLen {
+			t.Errorf("truncateWithEllipsis(%q, %d) is %d columns wide", tt.name, tt.maxLen, w)
+		}
+	}
+}
+
+func TestStreamCSVHasNoDependentsColumn(t *testing.T) {
+	savedFormat, savedStream, savedDepth := format, stream, depth
+	format, stream, depth = "csv", true, 2
+	t.Cleanup(func() { format, stream, depth = savedFormat, savedStream, savedDepth })
+
+	found := make(chan Repo, 1)
+	found <- Repo{Name: "dep", URL: "https://github.com/o/dep", Stars: 10, Forks: 1}
+	close(found)
+	var out bytes.Buffer
+	if err := streamRepos(&out, found, 0, func(Repo) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want a header and a row:\n%s", len(lines), out.String())
+	}
+	if strings.Contains(lines[0], "Dependents") || strings.HasSuffix(lines[1], ",0") {
+		t.Errorf("streamed CSV has a dependents column:\n%s", out.String())
+	}
+}
+
+func TestColumnNamesRenameCSVHeader(t *testing.T) {
+	if rootCmd.Flags().Lookup("column-names") == nil || rootCmd.Flags().Lookup("headers") != nil {
+		t.Fatal("columns should be renamed with --column-names, not --headers")
+	}
+	saved := columnHeaders
+	columnHeaders = map[string]string{"name": "Repository", "stars": "★"}
+	t.Cleanup(func() { columnHeaders = saved })
+
+	var out bytes.Buffer
+	if err := displayCSV(&out, []Repo{{Name: "dep", URL: "https://github.com/o/dep"}}); err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(out.String(), "\n")
+	if header != "Repository,URL,★,Forks" {
+		t.Errorf("CSV header = %q, want the renamed columns", header)
+	}
+}
+
+func TestFormatHumanNumber(t *testing.T) {
+	saved := precision
+	t.Cleanup(func() { precision = saved })
+
+	tests := []struct {
+		n         int
+		precision int
+		want      string
+	}{
+		{0, 1, "0"},
+		{999, 1, "999"},
+		{1000, 1, "1k"},
+		{12345, 1, "12.3k"},
+		{999949, 1, "999.9k"},
+		// Rounding up at a unit boundary moves to the next unit.
+		{999950, 1, "1m"},
+		{999999, 1, "1m"},
+		{999499, 0, "999k"},
+		{999500, 0, "1m"},
+		{1500000, 1, "1.5m"},
+		{999950000, 1, "1b"},
+		{999999999, 2, "1b"},
+		{1234567890, 2, "1.23b"},
+		// There is no unit above b.
+		{999999999999, 0, "1000b"},
+	}
+	for _, tt := range tests {
+		precision = tt.precision
+		if got := formatHumanNumber(tt.n); got != tt.want {
+			t.Errorf("formatHumanNumber(%d) with --precision %d = %q, want %q", tt.n, tt.precision, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jedib0t/go-pretty/progress"
+)
+
+// plainProgressInterval is the number of pages between status lines when
+// stderr is not a terminal.
+const plainProgressInterval = 10
+
+// PageProgress describes a crawl after each dependents page.
+type PageProgress struct {
+	Page int
+	// EstimatedPages is the number of pages expected from the total GitHub
+	// reports, or 0 if it isn't known.
+	EstimatedPages int
+	Fetched        int
+	Matching       int
+	// NextURL is the next dependents page, with its cursor, or "" after
+	// the last page.
+	NextURL string
+}
+
+// crawlProgress reports crawl progress on stderr. On a terminal it renders a
+// live progress bar; otherwise (e.g. in CI logs) it prints a plain status line
+// every plainProgressInterval pages. Nothing is reported with --quiet, unless
+// --progress-interval is set.
+type crawlProgress struct {
+	// mu serializes updates, which may come from concurrent page parsers.
+	mu          sync.Mutex
+	interactive bool
+	pw          progress.Writer
+	tracker     *progress.Tracker
+	started     time.Time
+}
+
+func newCrawlProgress() *crawlProgress {
+	interactive := !quiet && progressInterval == 0 && !stripANSI && isTerminal(os.Stderr)
+	p := &crawlProgress{interactive: interactive, started: time.Now()}
+	if p.interactive {
+		p.pw = progress.NewWriter()
+		p.pw.SetOutputWriter(os.Stderr)
+		p.pw.SetUpdateFrequency(time.Millisecond * 100)
+		p.pw.Style().Colors = progress.StyleColorsExample
+		// The overall tracker is the one go-pretty shows an ETA for.
+		p.pw.ShowOverallTracker(true)
+		p.pw.ShowTime(true)
+		p.pw.Style().Options.TimeOverallPrecision = time.Second
+		go p.pw.Render()
+	}
+	return p
+}
+
+// start adds the progress bar once the first page tells us how many pages to
+// expect. The tracker counts pages, from which go-pretty estimates the time
+// left.
+func (p *crawlProgress) start(estimatedPages int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.interactive {
+		return
+	}
+	p.tracker = &progress.Tracker{
+		Message: "Fetching dependents",
+		Total:   int64(estimatedPages),
+		Units:   progress.UnitsDefault,
+	}
+	p.pw.AppendTracker(p.tracker)
+}
+
+// report shows the progress after a page, starting the progress bar after the
+// first one.
+func (p *crawlProgress) report(page PageProgress) {
+	if page.Page == 1 {
+		p.start(page.EstimatedPages)
+	}
+	p.update(page)
+}
+
+// update reports the counters after a page. With --progress-interval, a status
+// line is printed every that many pages instead, even with --quiet.
+func (p *crawlProgress) update(page PageProgress) {
+	if quiet && progressInterval == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pages := int64(page.Page)
+	total := page.Fetched
+	matching := page.Matching
+
+	rate := float64(pages) / time.Since(p.started).Seconds()
+
+	status := fmt.Sprintf("Fetching dependents (Page: %d, Total: %d, Matching: %d, %.1f pages/s)", pages, total, matching, rate)
+	if onlyMatching {
+		status = fmt.Sprintf("Fetching dependents (Page: %d, Matching: %d, %.1f pages/s)", pages, matching, rate)
+	}
+
+	if p.interactive {
+		p.tracker.SetValue(pages)
+		fmt.Fprintf(os.Stderr, "\r%s", status)
+		return
+	}
+	interval := int64(plainProgressInterval)
+	if progressInterval > 0 {
+		interval = int64(progressInterval)
+	}
+	if pages%interval == 0 {
+		fmt.Fprintln(os.Stderr, status)
+	}
+}
+
+// done stops the progress bar. It is safe to call more than once, and on a
+// nil crawlProgress.
+func (p *crawlProgress) done() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.interactive {
+		return
+	}
+	if p.tracker != nil {
+		p.tracker.MarkAsDone()
+	}
+	p.pw.Stop()
+	fmt.Fprintln(os.Stderr)
+	p.interactive = false
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a pipe
+// or a file. Terminals declaring themselves dumb don't count, since they
+// can't render escape sequences.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFetchDependentsCallsOnPageOncePerPage(t *testing.T) {
+	quietCrawl(t)
+	_, repoURL := newDependentsServer(t, 3, 2)
+
+	var calls []PageProgress
+	repos, stats, err := fetchDependents(repoURL, typeRepository, nil, func(p PageProgress) {
+		calls = append(calls, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 6 || stats.Pages != 3 {
+		t.Fatalf("got %d repos on %d pages, want 6 on 3", len(repos), stats.Pages)
+	}
+	if len(calls) != 3 {
+		t.Fatalf("onPage called %d times, want 3", len(calls))
+	}
+	for i, p := range calls {
+		if p.Page != i+1 || p.Fetched != 2*(i+1) || p.EstimatedPages != 3 {
+			t.Errorf("call %d = %+v, want page %d with %d fetched of 3 pages", i, p, i+1, 2*(i+1))
+		}
+		if last := i == len(calls)-1; last != (p.NextURL == "") {
+			t.Errorf("call %d has NextURL %q", i, p.NextURL)
+		}
+	}
+	if !strings.HasSuffix(calls[0].NextURL, "page=2") {
+		t.Errorf("first NextURL = %q, want the page 2 URL", calls[0].NextURL)
+	}
+}
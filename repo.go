@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// normalizeRepoURL strips trailing slashes, an already present
+// /network/dependents suffix, a query string and a .git suffix from a
+// repository URL given on the command line, so users can paste the
+// repository, its clone URL or its dependents page. The
+// owner/name shorthand and URLs without a scheme are taken to be on GitHub.
+func normalizeRepoURL(repoURL string) string {
+	repoURL = strings.TrimSpace(repoURL)
+	if !strings.Contains(repoURL, "://") {
+		if strings.HasPrefix(strings.ToLower(repoURL), "github.com/") {
+			repoURL = "https://" + repoURL
+		} else {
+			repoURL = githubURL + "/" + strings.TrimLeft(repoURL, "/")
+		}
+	}
+	if i := strings.IndexAny(repoURL, "?#"); i >= 0 {
+		repoURL = repoURL[:i]
+	}
+	repoURL = strings.TrimRight(repoURL, "/")
+	repoURL = strings.TrimSuffix(repoURL, "/network/dependents")
+	repoURL = strings.TrimRight(repoURL, "/")
+	return strings.TrimSuffix(repoURL, ".git")
+}
+
+// repoFullName returns the "owner/name" part of a GitHub repository URL, or
+// an empty string when the URL doesn't point at a repository.
+func repoFullName(repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return ""
+	}
+	return parts[0] + "/" + parts[1]
+}
+
+// repoOwner returns the owner of a GitHub repository URL.
+func repoOwner(repoURL string) string {
+	owner, _, _ := strings.Cut(repoFullName(repoURL), "/")
+	return owner
+}
+
+// mergeRepos deduplicates repos by URL, keeping the first occurrence with the
+// highest star and fork counts seen for it.
+func mergeRepos(repos []Repo) []Repo {
+	index := make(map[string]int)
+	var result []Repo
+	for _, repo := range repos {
+		key := strings.ToLower(repo.URL)
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(result)
+			result = append(result, repo)
+			continue
+		}
+		result[i].Stars = max(result[i].Stars, repo.Stars)
+		result[i].Forks = max(result[i].Forks, repo.Forks)
+		result[i].StarsMissing = result[i].StarsMissing && repo.StarsMissing
+	}
+	return result
+}
+
+// collapsePackages lists each repository of package dependents once, for
+// --collapse-monorepo-packages. Stars and forks belong to the repository, so
+// the highest counts seen are kept as with mergeRepos rather than summed, and
+// the names of its packages are joined with commas.
+func collapsePackages(repos []Repo) []Repo {
+	index := make(map[string]int)
+	packages := make(map[string]bool)
+	var result []Repo
+	for _, repo := range repos {
+		key := strings.ToLower(repo.URL)
+		packageKey := key + " " + repo.PackageName
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(result)
+			packages[packageKey] = true
+			result = append(result, repo)
+			continue
+		}
+		result[i].Stars = max(result[i].Stars, repo.Stars)
+		result[i].Forks = max(result[i].Forks, repo.Forks)
+		result[i].StarsMissing = result[i].StarsMissing && repo.StarsMissing
+		if repo.PackageName != "" && !packages[packageKey] {
+			packages[packageKey] = true
+			if result[i].PackageName != "" {
+				result[i].PackageName += ", "
+			}
+			result[i].PackageName += repo.PackageName
+		}
+	}
+	return result
+}
+
+// normalizeRepoStars sets the NormalizedStars of each repo to its stars
+// divided by the most stars among repos, so results of packages with very
+// different reach can be compared.
+func normalizeRepoStars(repos []Repo) {
+	most := 0
+	for _, repo := range repos {
+		most = max(most, repo.Stars)
+	}
+	for i := range repos {
+		normalized := 0.0
+		if most > 0 {
+			normalized = float64(repos[i].Stars) / float64(most)
+		}
+		repos[i].NormalizedStars = &normalized
+	}
+}
+
+// setStarPercentiles sets the Percentile of each repo to the percentile rank
+// of its stars among all: the share of them with fewer stars, counting ties
+// as half.
+func setStarPercentiles(repos, all []Repo) {
+	stars := make([]int, len(all))
+	for i, repo := range all {
+		stars[i] = repo.Stars
+	}
+	sort.Ints(stars)
+	for i := range repos {
+		below := sort.SearchInts(stars, repos[i].Stars)
+		equal := sort.SearchInts(stars, repos[i].Stars+1) - below
+		percentile := 0.0
+		if len(stars) > 0 {
+			percentile = 100 * (float64(below) + float64(equal)/2) / float64(len(stars))
+		}
+		repos[i].Percentile = &percentile
+	}
+}
+
+// countStarsMissing returns the number of repos without a star count.
+func countStarsMissing(repos []Repo) int {
+	n := 0
+	for _, repo := range repos {
+		if repo.StarsMissing {
+			n++
+		}
+	}
+	return n
+}
+
+// countNameCollisions returns the number of repos sharing their bare name
+// with another one, compared case-insensitively.
+func countNameCollisions(repos []Repo) int {
+	names := make(map[string]int)
+	for _, repo := range repos {
+		names[strings.ToLower(repo.Name)]++
+	}
+	n := 0
+	for _, count := range names {
+		if count > 1 {
+			n += count
+		}
+	}
+	return n
+}
+
+// countOwners returns the number of distinct owners of repos.
+func countOwners(repos []Repo) int {
+	owners := make(map[string]bool)
+	for _, repo := range repos {
+		owners[strings.ToLower(repoOwner(repo.URL))] = true
+	}
+	return len(owners)
+}
+
+// displayName is the name shown for a repo in the table: "owner/name" so that
+// repos sharing a name stay distinguishable, or the bare name with
+// --short-names.
+func displayName(repo Repo) string {
+	if shortNames {
+		return repo.Name
+	}
+	if fullName := repoFullName(repo.URL); fullName != "" {
+		return fullName
+	}
+	return repo.Name
+}
+
+// dependentCount returns the dependent count of repo, or 0 if it wasn't
+// fetched.
+func dependentCount(repo Repo) int {
+	if repo.DependentCount == nil {
+		return 0
+	}
+	return *repo.DependentCount
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestNormalizeRepoURL(t *testing.T) {
+	const want = "https://github.com/owner/repo"
+	for _, input := range []string{
+		"https://github.com/owner/repo",
+		"https://github.com/owner/repo/",
+		"https://github.com/owner/repo//",
+		"https://github.com/owner/repo/network/dependents",
+		"https://github.com/owner/repo/network/dependents/",
+		"https://github.com/owner/repo/network/dependents?dependent_type=PACKAGE",
+		"https://github.com/owner/repo?tab=readme#usage",
+		"https://github.com/owner/repo.git",
+		"https://github.com/owner/repo.git/",
+		"  https://github.com/owner/repo  ",
+		"github.com/owner/repo",
+		"owner/repo",
+	} {
+		if got := normalizeRepoURL(input); got != want {
+			t.Errorf("normalizeRepoURL(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestNormalizeRepoURLKeepsOtherHosts(t *testing.T) {
+	const input = "http://127.0.0.1:8080/owner/repo/network/dependents"
+	if got, want := normalizeRepoURL(input), "http://127.0.0.1:8080/owner/repo"; got != want {
+		t.Errorf("normalizeRepoURL(%q) = %q, want %q", input, got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// repoCacheTTL is how long details looked up with the API are reused by
+// later runs, from --repo-cache-ttl. 0 disables the cache.
+var repoCacheTTL time.Duration
+
+// repoCacheEntry holds the API details of one repository, each with the time
+// it was looked up.
+type repoCacheEntry struct {
+	Info     *repoInfo `json:"info,omitempty"`
+	InfoAt   time.Time `json:"info_at,omitempty"`
+	Stars    int       `json:"stars,omitempty"`
+	Forks    int       `json:"forks,omitempty"`
+	CountsAt time.Time `json:"counts_at,omitempty"`
+}
+
+// repoCache is the persistent cache of API details, keyed by lowercase
+// repository URL. It is shared by runs querying different repositories, so
+// dependents they have in common are only looked up once per TTL.
+var repoCache = struct {
+	sync.Mutex
+	path    string
+	entries map[string]repoCacheEntry
+	changed bool
+}{entries: make(map[string]repoCacheEntry)}
+
+// defaultRepoCacheFile returns where the repo cache is stored, e.g.
+// ~/.cache/topdep/repos.json on Linux, or "" if there is no cache directory.
+func defaultRepoCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "topdep", "repos.json")
+}
+
+// loadRepoCache reads the repo cache from path. A missing file is an empty
+// cache.
+func loadRepoCache(path string) error {
+	repoCache.Lock()
+	defer repoCache.Unlock()
+	repoCache.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &repoCache.entries)
+}
+
+// saveRepoCache writes the repo cache back if anything was looked up,
+// dropping expired entries.
+func saveRepoCache() error {
+	repoCache.Lock()
+	defer repoCache.Unlock()
+	if repoCache.path == "" || !repoCache.changed {
+		return nil
+	}
+	for key, entry := range repoCache.entries {
+		if !fresh(entry.InfoAt) && !fresh(entry.CountsAt) {
+			delete(repoCache.entries, key)
+		}
+	}
+	data, err := json.Marshal(repoCache.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(repoCache.path), 0o755); err != nil {
+		return err
+	}
+	// Written to a temporary file first, so an interrupted run doesn't
+	// leave a truncated cache.
+	tmp := repoCache.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, repoCache.path); err != nil {
+		return err
+	}
+	repoCache.changed = false
+	return nil
+}
+
+func repoCacheKey(repoURL string) string {
+	return strings.ToLower(repoURL)
+}
+
+// fresh reports whether something looked up at t is still within the TTL.
+func fresh(t time.Time) bool {
+	return repoCacheTTL > 0 && !t.IsZero() && time.Since(t) < repoCacheTTL
+}
+
+// cachedRepoInfo returns the cached API details of the repository at
+// repoURL, if they are fresh.
+func cachedRepoInfo(repoURL string) (repoInfo, bool) {
+	repoCache.Lock()
+	defer repoCache.Unlock()
+	entry := repoCache.entries[repoCacheKey(repoURL)]
+	if entry.Info == nil || !fresh(entry.InfoAt) {
+		return repoInfo{}, false
+	}
+	return *entry.Info, true
+}
+
+func cacheRepoInfo(repoURL string, info repoInfo) {
+	if repoCacheTTL <= 0 {
+		return
+	}
+	repoCache.Lock()
+	defer repoCache.Unlock()
+	key := repoCacheKey(repoURL)
+	entry := repoCache.entries[key]
+	entry.Info, entry.InfoAt = &info, time.Now()
+	repoCache.entries[key] = entry
+	repoCache.changed = true
+}
+
+// cachedCounts returns the cached exact star and fork counts of the
+// repository at repoURL, if they are fresh.
+func cachedCounts(repoURL string) (stars, forks int, ok bool) {
+	repoCache.Lock()
+	defer repoCache.Unlock()
+	entry := repoCache.entries[repoCacheKey(repoURL)]
+	if !fresh(entry.CountsAt) {
+		return 0, 0, false
+	}
+	return entry.Stars, entry.Forks, true
+}
+
+func cacheCounts(repoURL string, stars, forks int) {
+	if repoCacheTTL <= 0 {
+		return
+	}
+	repoCache.Lock()
+	defer repoCache.Unlock()
+	key := repoCacheKey(repoURL)
+	entry := repoCache.entries[key]
+	entry.Stars, entry.Forks, entry.CountsAt = stars, forks, time.Now()
+	repoCache.entries[key] = entry
+	repoCache.changed = true
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Results is a list of dependents with chainable methods to filter, sort and
+// cut it down, for example the ten most starred dependents with at least 50
+// stars:
+//
+//	top := Results(repos).FilterByStars(50).SortBy("stars").Top(10)
+//
+// The methods return new lists and leave the one they are called on as is.
+type Results []Repo
+
+// ResultsSummary are totals over a list of dependents.
+type ResultsSummary struct {
+	Count    int
+	Owners   int
+	Stars    int
+	Forks    int
+	MaxStars int
+}
+
+// FilterByStars returns the dependents with at least min stars.
+func (r Results) FilterByStars(min int) Results {
+	var result Results
+	for _, repo := range r {
+		if repo.Stars >= min {
+			result = append(result, repo)
+		}
+	}
+	return result
+}
+
+// SortBy returns the dependents sorted by the first of keys, and by each next
+// one among dependents the previous keys rank equal: stars, forks and
+// dependents in descending order, name alphabetically by owner/name. Empty
+// keys are skipped and remaining ties keep their order, so
+//
+//	Results(repos).SortBy("forks", "name")
+//
+// sorts by forks, and repos with as many forks by name.
+func (r Results) SortBy(keys ...string) Results {
+	result := append(Results(nil), r...)
+	sort.SliceStable(result, func(i, j int) bool {
+		for _, key := range keys {
+			if key == "" {
+				continue
+			}
+			if c := compareRepos(result[i], result[j], key); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return result
+}
+
+// Shuffle returns the dependents in a random order drawn from rng.
+func (r Results) Shuffle(rng *rand.Rand) Results {
+	result := append(Results(nil), r...)
+	rng.Shuffle(len(result), func(i, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+	return result
+}
+
+// MaxPerOwner returns the dependents without those of owners already listed
+// n times, or all of them if n is 0. Owners are compared case-insensitively.
+func (r Results) MaxPerOwner(n int) Results {
+	if n == 0 {
+		return r
+	}
+	var result Results
+	counts := make(map[string]int)
+	for _, repo := range r {
+		owner := strings.ToLower(repoOwner(repo.URL))
+		if counts[owner] < n {
+			counts[owner]++
+			result = append(result, repo)
+		}
+	}
+	return result
+}
+
+// Top returns the first n dependents, or all of them if n is 0.
+func (r Results) Top(n int) Results {
+	if n > 0 && len(r) > n {
+		return r[:n]
+	}
+	return r
+}
+
+// Summary returns the number of dependents and distinct owners, and the
+// totals of their stars and forks.
+func (r Results) Summary() ResultsSummary {
+	s := ResultsSummary{Count: len(r), Owners: countOwners(r)}
+	for _, repo := range r {
+		s.Stars += repo.Stars
+		s.Forks += repo.Forks
+		s.MaxStars = max(s.MaxStars, repo.Stars)
+	}
+	return s
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// testRepo returns the dependent owner/name with stars and forks.
+func testRepo(owner, name string, stars, forks int) Repo {
+	return Repo{Name: name, URL: githubURL + "/" + owner + "/" + name, Stars: stars, Forks: forks}
+}
+
+// fullNames returns the owner/name of each result, in order.
+func fullNames(r Results) []string {
+	var names []string
+	for _, repo := range r {
+		names = append(names, repoFullName(repo.URL))
+	}
+	return names
+}
+
+var exampleResults = Results{
+	testRepo("acme", "web", 120, 4),
+	testRepo("acme", "cli", 80, 9),
+	testRepo("acme", "docs", 10, 1),
+	testRepo("zed", "app", 300, 9),
+	testRepo("bob", "tool", 45, 2),
+}
+
+func ExampleResults() {
+	top := exampleResults.FilterByStars(50).SortBy("stars").Top(2)
+	for _, repo := range top {
+		fmt.Println(repoFullName(repo.URL), repo.Stars)
+	}
+	// Output:
+	// zed/app 300
+	// acme/web 120
+}
+
+func ExampleResults_SortBy() {
+	for _, repo := range exampleResults.SortBy("forks", "name") {
+		fmt.Println(repoFullName(repo.URL), repo.Forks)
+	}
+	// Output:
+	// acme/cli 9
+	// zed/app 9
+	// acme/web 4
+	// bob/tool 2
+	// acme/docs 1
+}
+
+func ExampleResults_MaxPerOwner() {
+	for _, repo := range exampleResults.SortBy("stars").MaxPerOwner(1) {
+		fmt.Println(repoFullName(repo.URL))
+	}
+	// Output:
+	// zed/app
+	// acme/web
+	// bob/tool
+}
+
+func ExampleResults_Summary() {
+	s := exampleResults.Summary()
+	fmt.Printf("%d dependents by %d owners, %d stars, %d forks, at most %d stars\n",
+		s.Count, s.Owners, s.Stars, s.Forks, s.MaxStars)
+	// Output:
+	// 5 dependents by 3 owners, 555 stars, 25 forks, at most 300 stars
+}
+
+func TestResultsFilterByStars(t *testing.T) {
+	tests := []struct {
+		min  int
+		want []string
+	}{
+		{0, []string{"acme/web", "acme/cli", "acme/docs", "zed/app", "bob/tool"}},
+		{45, []string{"acme/web", "acme/cli", "zed/app", "bob/tool"}},
+		{121, []string{"zed/app"}},
+		{301, nil},
+	}
+	for _, tt := range tests {
+		if got := fullNames(exampleResults.FilterByStars(tt.min)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FilterByStars(%d) = %v, want %v", tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestResultsSortBy(t *testing.T) {
+	withDependents := func(repo Repo, n int) Repo {
+		repo.DependentCount = &n
+		return repo
+	}
+	repos := Results{
+		withDependents(testRepo("acme", "web", 120, 4), 3),
+		withDependents(testRepo("Acme", "CLI", 80, 9), 7),
+		testRepo("zed", "app", 120, 9),
+	}
+	tests := []struct {
+		keys []string
+		want []string
+	}{
+		{[]string{"stars"}, []string{"acme/web", "zed/app", "Acme/CLI"}},
+		{[]string{"stars", "forks"}, []string{"zed/app", "acme/web", "Acme/CLI"}},
+		{[]string{"forks", "name"}, []string{"Acme/CLI", "zed/app", "acme/web"}},
+		{[]string{"name"}, []string{"Acme/CLI", "acme/web", "zed/app"}},
+		{[]string{"dependents"}, []string{"Acme/CLI", "acme/web", "zed/app"}},
+		// Empty keys are skipped, and no keys keep the order.
+		{[]string{"", "forks"}, []string{"Acme/CLI", "zed/app", "acme/web"}},
+		{nil, []string{"acme/web", "Acme/CLI", "zed/app"}},
+	}
+	for _, tt := range tests {
+		if got := fullNames(repos.SortBy(tt.keys...)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SortBy(%q) = %v, want %v", tt.keys, got, tt.want)
+		}
+	}
+	if got := fullNames(repos); got[0] != "acme/web" || got[1] != "Acme/CLI" {
+		t.Errorf("SortBy reordered the list it was called on: %v", got)
+	}
+}
+
+func TestResultsShuffle(t *testing.T) {
+	a := exampleResults.Shuffle(rand.New(rand.NewSource(1)))
+	b := exampleResults.Shuffle(rand.New(rand.NewSource(1)))
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("shuffles with the same seed differ: %v and %v", fullNames(a), fullNames(b))
+	}
+	if !reflect.DeepEqual(a.SortBy("name"), exampleResults.SortBy("name")) {
+		t.Errorf("Shuffle changed the dependents: %v", fullNames(a))
+	}
+	if got := fullNames(exampleResults); got[0] != "acme/web" || got[4] != "bob/tool" {
+		t.Errorf("Shuffle reordered the list it was called on: %v", got)
+	}
+}
+
+func TestResultsMaxPerOwner(t *testing.T) {
+	repos := append(Results{testRepo("ACME", "lib", 1, 0)}, exampleResults...)
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{0, []string{"ACME/lib", "acme/web", "acme/cli", "acme/docs", "zed/app", "bob/tool"}},
+		{1, []string{"ACME/lib", "zed/app", "bob/tool"}},
+		{2, []string{"ACME/lib", "acme/web", "zed/app", "bob/tool"}},
+		{4, []string{"ACME/lib", "acme/web", "acme/cli", "acme/docs", "zed/app", "bob/tool"}},
+	}
+	for _, tt := range tests {
+		if got := fullNames(repos.MaxPerOwner(tt.n)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MaxPerOwner(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestResultsTop(t *testing.T) {
+	tests := []struct {
+		n    int
+		want int
+	}{
+		{0, 5},
+		{2, 2},
+		{5, 5},
+		{10, 5},
+	}
+	for _, tt := range tests {
+		if got := len(exampleResults.Top(tt.n)); got != tt.want {
+			t.Errorf("Top(%d) has %d dependents, want %d", tt.n, got, tt.want)
+		}
+	}
+	if got := fullNames(exampleResults.Top(2)); !reflect.DeepEqual(got, []string{"acme/web", "acme/cli"}) {
+		t.Errorf("Top(2) = %v, want the first two", got)
+	}
+}
+
+func TestResultsSummary(t *testing.T) {
+	if got := (Results{}).Summary(); got != (ResultsSummary{}) {
+		t.Errorf("empty Summary() = %+v, want zero", got)
+	}
+	got := Results{testRepo("acme", "a", 3, 1), testRepo("Acme", "b", 7, 2)}.Summary()
+	want := ResultsSummary{Count: 2, Owners: 1, Stars: 10, Forks: 3, MaxStars: 7}
+	if got != want {
+		t.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+)
+
+// sampleRepos picks n random repos out of pool. When weighted is set, repos
+// with more stars are proportionally more likely to be picked. The sample is
+// returned sorted by stars like the top-N output.
+func sampleRepos(pool []Repo, n int, weighted bool, rng *rand.Rand) []Repo {
+	var result []Repo
+	if n >= len(pool) {
+		// Copied, since it is sorted below.
+		result = slices.Clone(pool)
+	} else if weighted {
+		result = weightedSample(pool, n, rng)
+	} else {
+		for _, i := range rng.Perm(len(pool))[:n] {
+			result = append(result, pool[i])
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Stars > result[j].Stars
+	})
+	return result
+}
+
+// weightedSample draws n repos without replacement, weighting each by its
+// star count plus one so that zero-star repos can still be picked.
+func weightedSample(pool []Repo, n int, rng *rand.Rand) []Repo {
+	remaining := append([]Repo(nil), pool...)
+	total := 0
+	for _, repo := range remaining {
+		total += repo.Stars + 1
+	}
+
+	result := make([]Repo, 0, n)
+	for len(result) < n {
+		target := rng.Intn(total)
+		for i, repo := range remaining {
+			target -= repo.Stars + 1
+			if target < 0 {
+				result = append(result, repo)
+				total -= repo.Stars + 1
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSampleReposLeavesPoolAlone(t *testing.T) {
+	for _, n := range []int{2, 3, 10} {
+		for _, weighted := range []bool{false, true} {
+			pool := []Repo{testRepo("o", "a", 1, 0), testRepo("o", "b", 3, 0), testRepo("o", "c", 2, 0)}
+			sample := sampleRepos(pool, n, weighted, rand.New(rand.NewSource(1)))
+			if got := fullNames(pool); !reflect.DeepEqual(got, []string{"o/a", "o/b", "o/c"}) {
+				t.Errorf("sampleRepos(%d, weighted %v) reordered the pool: %v", n, weighted, got)
+			}
+			if want := min(n, len(pool)); len(sample) != want {
+				t.Errorf("sampleRepos(%d, weighted %v) picked %d, want %d", n, weighted, len(sample), want)
+			}
+			for i := 1; i < len(sample); i++ {
+				if sample[i-1].Stars < sample[i].Stars {
+					t.Errorf("sampleRepos(%d, weighted %v) isn't sorted by stars: %v", n, weighted, fullNames(sample))
+				}
+			}
+		}
+	}
+}
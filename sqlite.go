@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables written by --format sqlite. Each run adds a
+// row to runs, describing its query and crawl, and a row to dependents for
+// every dependent it fetched. rank is the position of a dependent in the
+// output, or NULL if it was filtered out or cut off by --rows, so
+//
+//	SELECT url, stars FROM dependents WHERE run_id = 1 AND rank IS NOT NULL ORDER BY rank
+//
+// lists what the other formats would show.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id             INTEGER PRIMARY KEY,
+	created_at     TEXT    NOT NULL,
+	urls           TEXT    NOT NULL,
+	dependent_type TEXT    NOT NULL,
+	minstar        INTEGER NOT NULL,
+	sort           TEXT    NOT NULL,
+	rows           INTEGER NOT NULL,
+	pages          INTEGER NOT NULL,
+	github_total   INTEGER NOT NULL,
+	fetched        INTEGER NOT NULL,
+	matching       INTEGER NOT NULL,
+	shown          INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS dependents (
+	run_id          INTEGER NOT NULL REFERENCES runs(id),
+	rank            INTEGER,
+	name            TEXT    NOT NULL,
+	url             TEXT    NOT NULL,
+	stars           INTEGER NOT NULL,
+	forks           INTEGER NOT NULL,
+	package_name    TEXT,
+	ecosystem       TEXT,
+	dependent_count INTEGER
+);
+CREATE INDEX IF NOT EXISTS dependents_run_id ON dependents (run_id);
+`
+
+// displaySQLite adds the run and all its fetched repos to the SQLite database
+// at path, creating it if needed. Earlier runs in the database are kept, so
+// results can be compared across runs with SQL.
+func displaySQLite(path string, repos, shown []Repo, query queryMeta, meta crawlMeta) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return fmt.Errorf("creating tables: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO runs (created_at, urls, dependent_type, minstar, sort, rows, pages, github_total, fetched, matching, shown)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC().Format(time.RFC3339), strings.Join(query.URLs, " "), query.DependentType, query.MinStars,
+		query.Sort, query.Rows, meta.Pages, meta.GitHubTotal, meta.Fetched, meta.Matching, meta.Shown)
+	if err != nil {
+		return fmt.Errorf("inserting run: %v", err)
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	ranks := make(map[string]int)
+	for i, repo := range shown {
+		ranks[dedupKey(repo)] = i + 1
+	}
+	// Shown repos may have been enriched, e.g. with exact counts or dependent
+	// counts, so they are inserted as shown rather than as fetched.
+	insert := append([]Repo(nil), shown...)
+	for _, repo := range repos {
+		if _, ok := ranks[dedupKey(repo)]; !ok {
+			insert = append(insert, repo)
+		}
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO dependents (run_id, rank, name, url, stars, forks, package_name, ecosystem, dependent_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, repo := range insert {
+		var rank, dependents sql.NullInt64
+		if r, ok := ranks[dedupKey(repo)]; ok {
+			rank = sql.NullInt64{Int64: int64(r), Valid: true}
+		}
+		if repo.DependentCount != nil {
+			// Only counts that weren't fetched are NULL, 0 is a count.
+			dependents = sql.NullInt64{Int64: int64(*repo.DependentCount), Valid: true}
+		}
+		_, err := stmt.Exec(runID, rank, repo.Name, repo.URL, repo.Stars, repo.Forks,
+			nullString(repo.PackageName), nullString(repo.Ecosystem), dependents)
+		if err != nil {
+			return fmt.Errorf("inserting %s: %v", repo.URL, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// nullString stores empty strings as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDisplaySQLiteStoresFetchedZeroDependentCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.db")
+	zero := 0
+	shown := []Repo{{Name: "zero", URL: "https://github.com/o/zero", DependentCount: &zero}}
+	repos := append(shown, Repo{Name: "unfetched", URL: "https://github.com/o/unfetched"})
+	if err := displaySQLite(path, repos, shown, queryMeta{DependentType: typePackage}, crawlMeta{}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for name, want := range map[string]sql.NullInt64{
+		"zero":      {Int64: 0, Valid: true},
+		"unfetched": {},
+	} {
+		var got sql.NullInt64
+		if err := db.QueryRow("SELECT dependent_count FROM dependents WHERE name = ?", name).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("dependent_count of %s = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestDisplaySQLiteStoresRenamedRepoOnce(t *testing.T) {
+	quietCrawl(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/o/old" {
+			http.Redirect(w, r, "/o/new", http.StatusMovedPermanently)
+			return
+		}
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer srv.Close()
+
+	shown := []Repo{{Name: "old", URL: srv.URL + "/o/old", Stars: 10}}
+	repos := append(shown, Repo{Name: "other", URL: "https://github.com/o/other"})
+	resolved, err := enrichRepos(shown, 1, resolveRedirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos = applyRedirects(repos, shown, resolved)
+
+	path := filepath.Join(t.TempDir(), "runs.db")
+	if err := displaySQLite(path, repos, resolved, queryMeta{}, crawlMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT url, rank FROM dependents ORDER BY url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var url string
+		var rank sql.NullInt64
+		if err := rows.Scan(&url, &rank); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s %v", url, rank.Int64))
+	}
+	want := []string{"https://github.com/o/new 1", "https://github.com/o/other 0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dependents rows = %v, want %v", got, want)
+	}
+}